@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"fmt"
+
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/table"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "list the cache entries, with the cluster and workload they come from",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runList(cmd); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runList(cmd *cobra.Command) error {
+	es, err := entries()
+	if err != nil {
+		return err
+	}
+
+	if len(es) == 0 && !cmd.Flags().Changed("output") {
+		fn.Log(text.Blue("[#] nothing is cached"))
+		return nil
+	}
+
+	header := table.Row{
+		table.HeaderText("id"),
+		table.HeaderText("type"),
+		table.HeaderText("context"),
+		table.HeaderText("namespace"),
+		table.HeaderText("workload"),
+		table.HeaderText("container"),
+		table.HeaderText("source"),
+		table.HeaderText("vars"),
+		table.HeaderText("age"),
+	}
+
+	rows := make([]table.Row, 0, len(es))
+	for _, e := range es {
+		if e.env == nil {
+			workload := "-"
+			if e.typ == typeEnv {
+				workload = text.Yellow("unreadable")
+			}
+			rows = append(rows, table.Row{e.id(), e.typ, "-", "-", workload, "-", "-", "-", e.age()})
+			continue
+		}
+
+		rows = append(rows, table.Row{
+			e.id(),
+			e.typ,
+			orDash(e.env.Context),
+			e.env.Namespace,
+			e.env.Workload,
+			e.env.Container,
+			orDash(string(e.env.Source)),
+			fmt.Sprint(len(e.env.Envs)),
+			e.age(),
+		})
+	}
+
+	fn.Println(table.Table(&header, rows, cmd))
+	return nil
+}
+
+func init() {
+	fn.WithOutputVariant(listCmd)
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/abdheshnayak/inkube/pkg/cache"
+	"github.com/abdheshnayak/inkube/pkg/connect"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "cache",
+	Short: "list, inspect and purge the env vars cached from the cluster",
+}
+
+const (
+	typeEnv     = "env"
+	typeKubeVpn = "kubevpn status"
+)
+
+// entry is a file of the cache directory inkube knows about.
+type entry struct {
+	cache.File
+	typ string
+
+	// env is nil for the kubevpn status and for env files that can't be
+	// read, written with another key or an older format.
+	env *kube.EnvCacheEntry
+}
+
+// id is the short name entries are referred to with.
+func (e *entry) id() string {
+	if e.typ == typeKubeVpn {
+		return "kubevpn"
+	}
+
+	id := strings.TrimSuffix(strings.TrimPrefix(e.Name, "env-"), kube.EnvCacheSuffix)
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func (e *entry) age() string {
+	return duration.HumanDuration(time.Since(e.ModTime))
+}
+
+// matches tells whether target refers to e, by id or by workload, like
+// deployment/api, api or app/deployment/api with the namespace.
+func (e *entry) matches(target string) bool {
+	if target == "" {
+		return false
+	}
+
+	full := strings.TrimSuffix(strings.TrimPrefix(e.Name, "env-"), kube.EnvCacheSuffix)
+	if strings.HasPrefix(full, target) || target == e.id() {
+		return true
+	}
+
+	if e.env == nil {
+		return false
+	}
+
+	_, name, _ := strings.Cut(e.env.Workload, "/")
+	return target == e.env.Workload || target == name || target == e.env.Namespace+"/"+e.env.Workload
+}
+
+// entries returns the env caches and the kubevpn status cache, oldest first.
+func entries() ([]*entry, error) {
+	files, err := cache.List()
+	if err != nil {
+		return nil, fn.Errorf("failed to list the cache directory: %w", err)
+	}
+
+	var es []*entry
+	for _, f := range files {
+		switch {
+		case kube.IsEnvCache(f.Name):
+			e := &entry{File: f, typ: typeEnv}
+			if env, err := kube.ReadEnvCache(f.Name); err == nil {
+				e.env = env
+			}
+			es = append(es, e)
+
+		case f.Name == connect.KubeVpnStatusCache:
+			es = append(es, &entry{File: f, typ: typeKubeVpn})
+		}
+	}
+
+	return es, nil
+}
+
+// find returns the entries target refers to.
+func find(target string) ([]*entry, error) {
+	es, err := entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*entry
+	for _, e := range es {
+		if e.matches(target) {
+			found = append(found, e)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, fn.Errorf("no cache entry matches %q, see `inkube cache list`", target)
+	}
+
+	return found, nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func describe(e *entry) string {
+	if e.env == nil {
+		return e.id()
+	}
+	return fmt.Sprintf("%s (%s/%s %s)", e.id(), e.env.Namespace, e.env.Workload, e.env.Container)
+}
+
+func init() {
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(showCmd)
+	Cmd.AddCommand(purgeCmd)
+}
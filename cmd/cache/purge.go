@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/abdheshnayak/inkube/pkg/cache"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge [id | workload]...",
+	Short: "remove cache entries, the given ones, all of them with --all or the old ones with --older-than",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runPurge(cmd, args); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runPurge(cmd *cobra.Command, targets []string) error {
+	all := fn.ParseBoolFlag(cmd, "all")
+	olderThan := fn.ParseStringFlag(cmd, "older-than")
+
+	if all && len(targets) > 0 {
+		return fn.Errorf("--all can't be used with cache entries")
+	}
+
+	if !all && olderThan == "" && len(targets) == 0 {
+		return fn.Errorf("nothing to purge, pass cache entries, --all or --older-than")
+	}
+
+	var age time.Duration
+	if olderThan != "" {
+		var err error
+		if age, err = time.ParseDuration(olderThan); err != nil {
+			return fn.Errorf("invalid --older-than %q, expected a duration like 72h: %w", olderThan, err)
+		}
+	}
+
+	var es []*entry
+	if len(targets) == 0 {
+		var err error
+		if es, err = entries(); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range targets {
+		found, err := find(t)
+		if err != nil {
+			return err
+		}
+		es = append(es, found...)
+	}
+
+	removed := map[string]bool{}
+	for _, e := range es {
+		if removed[e.Name] || (age > 0 && time.Since(e.ModTime) < age) {
+			continue
+		}
+
+		if err := cache.Remove(e.Name); err != nil {
+			return fn.Errorf("failed to remove %s: %w", describe(e), err)
+		}
+		removed[e.Name] = true
+
+		fn.Debug(text.Gray("removed " + describe(e)))
+	}
+
+	fn.Log(text.Blue(fmt.Sprintf("[#] removed %d cache entries", len(removed))))
+	return nil
+}
+
+func init() {
+	purgeCmd.Flags().Bool("all", false, "remove every cache entry")
+	purgeCmd.Flags().String("older-than", "", "only remove entries cached longer ago than this, e.g. 72h")
+}
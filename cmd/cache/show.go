@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/env"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+	"github.com/abdheshnayak/inkube/pkg/ui/table"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <id | workload>",
+	Short: "show a cache entry, secret values are redacted unless --reveal is passed",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runShow(args[0]); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runShow(target string) error {
+	es, err := find(target)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range es {
+		if i > 0 {
+			fn.Println()
+		}
+		show(e)
+	}
+
+	return nil
+}
+
+func show(e *entry) {
+	fn.Println(table.KVOutput("ID:", e.id(), false))
+	fn.Println(table.KVOutput("Type:", e.typ, false))
+	fn.Println(table.KVOutput("Cached:", fmt.Sprintf("%s (%s ago)", e.ModTime.Format(time.DateTime), e.age()), false))
+
+	if e.env == nil {
+		if e.typ == typeEnv {
+			fn.Println(text.Yellow("[!] the entry can't be read, it was written with another key or by an older inkube and will be refetched"))
+		}
+		return
+	}
+
+	fn.Println(table.KVOutput("Cluster:", fmt.Sprintf("%s (%s)", orDash(e.env.Server), orDash(e.env.Context)), false))
+	fn.Println(table.KVOutput("Workload:", e.env.Namespace+"/"+e.env.Workload, false))
+	fn.Println(table.KVOutput("Container:", e.env.Container, false))
+	fn.Println(table.KVOutput("Source:", orDash(string(e.env.Source)), false))
+
+	versions := make([]string, 0, len(e.env.Versions))
+	for ref, v := range e.env.Versions {
+		if v == "" {
+			v = "missing"
+		}
+		versions = append(versions, ref+"@"+v)
+	}
+	slices.Sort(versions)
+	fn.Println(table.KVOutput("Tracks:", orDash(strings.Join(versions, " ")), false))
+
+	if len(e.env.Envs) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(e.env.Envs))
+	for k := range e.env.Envs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	// entries written by an older inkube have no provenance, all their
+	// values are redacted
+	envs := env.FromCluster(&kube.Envs{Values: e.env.Envs, Provenance: e.env.Provenance})
+	redact := !flags.Reveal
+
+	header := table.Row{table.HeaderText("name"), table.HeaderText("value"), table.HeaderText("from")}
+	rows := make([]table.Row, 0, len(keys))
+	for _, k := range keys {
+		v := e.env.Envs[k]
+		switch {
+		case v == "":
+			v = text.Gray("(empty)")
+		case redact && (e.env.Provenance == nil || envs.IsSecret(k)):
+			v = fn.Redacted
+		}
+		rows = append(rows, table.Row{k, v, orDash(string(e.env.Provenance[k]))})
+	}
+
+	fn.Println()
+	fn.Println(table.Table(&header, rows))
+}
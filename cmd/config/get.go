@@ -0,0 +1,100 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get <path>",
+	Short: "print a config value, e.g. `inkube config get loadEnv.overrides`",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runGet(cmd, args[0]); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set <path> <value>",
+	Short: "set a config value, e.g. `inkube config set loadEnv.overrides.FOO bar`",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSet(cmd, args[0], args[1]); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset <path>",
+	Short: "remove a config value, e.g. `inkube config unset loadEnv.overrides.FOO`",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runUnset(cmd, args[0]); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runSet(cmd *cobra.Command, path string, raw string) error {
+	c, err := configFor(cmd)
+	if err != nil {
+		return err
+	}
+
+	return c.Set(path, raw)
+}
+
+func runUnset(cmd *cobra.Command, path string) error {
+	c, err := configFor(cmd)
+	if err != nil {
+		return err
+	}
+
+	return c.Unset(path)
+}
+
+func runGet(cmd *cobra.Command, path string) error {
+	c, err := configFor(cmd)
+	if err != nil {
+		return err
+	}
+
+	v, err := c.Get(path)
+	if err != nil {
+		return err
+	}
+
+	switch fn.ParseStringFlag(cmd, "output") {
+	case "json":
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fn.Println(string(b))
+	default:
+		switch v.(type) {
+		case map[string]any, []any:
+			b, err := yaml.Marshal(v)
+			if err != nil {
+				return err
+			}
+			fn.Println(strings.TrimSpace(string(b)))
+		default:
+			fn.Println(fmt.Sprint(v))
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	getCmd.Flags().StringP("output", "o", "yaml", "output format [yaml | json]")
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "config",
+	Short: "manage inkube config",
+}
+
+type editor interface {
+	Get(path string) (any, error)
+	Set(path string, raw string) error
+	Unset(path string) error
+}
+
+// configFor returns the config edited by cmd, the user config with --global
+// and the project one otherwise.
+func configFor(cmd *cobra.Command) (editor, error) {
+	if fn.ParseBoolFlag(cmd, "global") {
+		return config.NewUserConfig()
+	}
+
+	return config.Singleton(), nil
+}
+
+func init() {
+	Cmd.PersistentFlags().Bool("global", false, "use the user config in $XDG_CONFIG_HOME/inkube/config.yaml instead of inkube.yaml")
+
+	Cmd.AddCommand(migrateCmd)
+	Cmd.AddCommand(validateCmd)
+	Cmd.AddCommand(schemaCmd)
+	Cmd.AddCommand(getCmd)
+	Cmd.AddCommand(setCmd)
+	Cmd.AddCommand(unsetCmd)
+}
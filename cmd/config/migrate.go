@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [file...]",
+	Short: "upgrade config files to the current version, defaults to inkube.yaml and inkube.local.yaml",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runMigrate(args); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runMigrate(args []string) error {
+	files := args
+	if len(files) == 0 {
+		cpath, err := config.Find()
+		if err != nil {
+			return err
+		}
+
+		files = []string{cpath}
+		local := path.Join(path.Dir(cpath), config.LocalFileName)
+		if _, err := os.Stat(local); err == nil {
+			files = append(files, local)
+		}
+	}
+
+	for _, f := range files {
+		from, err := config.Migrate(f)
+		if err != nil {
+			return err
+		}
+
+		if from == config.CurrentVersion() {
+			fn.Log(text.Blue(fmt.Sprintf("[#] %s is already at %s", f, from)))
+			continue
+		}
+
+		fn.Log(text.Blue(fmt.Sprintf("[#] migrated %s from %s to %s, backup saved to %s.%s.bak", f, from, config.CurrentVersion(), f, from)))
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"encoding/json"
+
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "print the JSON Schema of inkube.yaml (or of the user config with --global), for editor completion",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSchema(cmd); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runSchema(cmd *cobra.Command) error {
+	schema := config.JSONSchema()
+	if fn.ParseBoolFlag(cmd, "global") {
+		schema = config.UserJSONSchema()
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fn.Println(string(b))
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"os"
+
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check inkube.yaml and the files it is merged with for mistakes",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runValidate(cmd); err != nil {
+			fn.PrintError(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func runValidate(cmd *cobra.Command) error {
+	if fn.ParseBoolFlag(cmd, "global") {
+		if _, err := config.NewUserConfig(); err != nil {
+			return err
+		}
+	} else if _, err := config.NewConfig(); err != nil {
+		return err
+	}
+
+	fn.Log(text.Green("[#] config is valid"))
+	return nil
+}
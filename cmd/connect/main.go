@@ -19,23 +19,31 @@ var Cmd = &cobra.Command{
 
 func Run(_ *cobra.Command, args []string) error {
 	cfg := config.Singleton()
+	t, err := cfg.Target()
+	if err != nil {
+		return err
+	}
 
-	please := "please run `inkube switch` to set the app name, namespace and container"
-	if cfg.Bridge.Name == "" {
+	please := "please run `inkube switch` to set the app name, namespace and containers"
+	if t.Bridge.Name == "" {
 		return fn.Errorf("deployment name is not set, %s", please)
 	}
 
-	if cfg.LoadEnv.Container == "" {
-		return fn.Errorf("container is not set, %s", please)
+	if len(t.LoadEnv.Containers) == 0 {
+		return fn.Errorf("containers are not set, %s", please)
 	}
 
-	if cfg.Namespace == "" {
+	if t.Namespace == "" {
 		return fn.Errorf("namespace is not set, %s", please)
 	}
 
-	if err := connect.SClient().Connect(cfg.Namespace); err != nil {
+	if err := connect.SClient().Connect(t.Namespace); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func init() {
+	fn.WithProfile(Cmd)
+}
@@ -5,9 +5,10 @@ import (
 	"os"
 	"time"
 
+	"github.com/abdheshnayak/inkube/flags"
 	"github.com/abdheshnayak/inkube/pkg/config"
 	"github.com/abdheshnayak/inkube/pkg/connect"
-	"github.com/abdheshnayak/inkube/pkg/devbox"
+	"github.com/abdheshnayak/inkube/pkg/env"
 	"github.com/abdheshnayak/inkube/pkg/fn"
 	"github.com/abdheshnayak/inkube/pkg/kube"
 	"github.com/abdheshnayak/inkube/pkg/shell"
@@ -28,24 +29,32 @@ var Cmd = &cobra.Command{
 
 func Run(cmd *cobra.Command, args []string) error {
 
+	if s := fn.ParseStringFlag(cmd, "shell"); s != "" {
+		flags.Shell = s
+	}
+
 	tele := connect.SClient()
 	cfg := config.Singleton()
+	t, err := cfg.Target()
+	if err != nil {
+		return err
+	}
 
-	please := "please run `inkube switch` to set the app name, namespace and container"
-	if cfg.Bridge.Name == "" {
-		return fn.Errorf("deployment name is not set, %s", please)
+	please := "please run `inkube switch` to set the app name, namespace and containers"
+	if t.Bridge.Name == "" {
+		return fn.Errorf("workload name is not set, %s", please)
 	}
 
-	if cfg.LoadEnv.Container == "" {
-		return fn.Errorf("container is not set, %s", please)
+	if len(t.LoadEnv.Containers) == 0 {
+		return fn.Errorf("containers are not set, %s", please)
 	}
 
-	if cfg.Namespace == "" {
+	if t.Namespace == "" {
 		return fn.Errorf("namespace is not set, %s", please)
 	}
 
 	if cfg.Connect {
-		if err := tele.Connect(cfg.Namespace); err != nil {
+		if err := tele.Connect(t.Namespace); err != nil {
 			return err
 		}
 
@@ -62,48 +71,44 @@ func Run(cmd *cobra.Command, args []string) error {
 	// 	}
 	// }()
 
-	envs := make(map[string]string)
-	if cfg.LoadEnv.Enabled {
-		kubeclient := kube.Singleton()
-
-		name := cfg.Bridge.Name
-		if cfg.LoadEnv.Name != nil {
-			name = *cfg.LoadEnv.Name
-		}
-
-		var err error
+	e, err := env.Build(cfg, t, env.Options{
+		Refetch: fn.ParseBoolFlag(cmd, "refetch"),
+		Mount:   true,
+	})
+	if err != nil {
+		return err
+	}
 
-		refetch := fn.ParseBoolFlag(cmd, "refetch")
-		envs, err = kubeclient.GetEnvs(cfg.Namespace, name, cfg.LoadEnv.Container, refetch)
-		if err != nil {
-			return err
-		}
+	envs := e.Vars
+	envs["INKUBE"] = "true"
+	envs["INKUBE_PROFILE"] = cfg.Profile()
 
-		maps.Copy(envs, cfg.LoadEnv.Overrides)
-	}
+	fn.Log(text.Blue("[#] entering inkube shell"))
 
-	if cfg.Devbox {
-		m, err := devbox.NewDevboxClient().ShellEnv()
+	// the shell keeps using the pinned cluster even if the current context
+	// of the kubeconfig changes while it runs
+	if kube.IsPinned() {
+		kc, err := kube.WriteSessionKubeconfig()
 		if err != nil {
 			return err
 		}
+		fn.OnCleanup(func() {
+			os.Remove(kc)
+		})
 
-		maps.Copy(envs, m)
-	}
-
-	envs["INKUBE"] = "true"
-
-	fn.Log(text.Blue("[#] entering inkube shell"))
-
-	dir, err := os.Getwd()
-	if err != nil {
-		return err
+		envs["KUBECONFIG"] = kc
 	}
 
 	envMaps := shell.PairsToMap(os.Environ())
 	maps.Copy(envMaps, envs)
 
-	ds, err := (&shell.Inkube{}).NewShell(shell.EnvOptions{}, shell.WithProjectDir(dir), shell.WithShellStartTime(time.Now()), shell.WithEnvVariables(envMaps))
+	// the shellrc only checks whether it is set
+	delete(envMaps, "INKUBE_NO_PROMPT")
+	if !flags.Prompt {
+		envMaps["INKUBE_NO_PROMPT"] = "true"
+	}
+
+	ds, err := (&shell.Inkube{}).NewShell(shell.EnvOptions{}, shell.WithProjectDir(cfg.Dir()), shell.WithShellStartTime(time.Now()), shell.WithEnvVariables(envMaps))
 
 	if err := ds.Run(); err != nil {
 		return err
@@ -115,4 +120,6 @@ func Run(cmd *cobra.Command, args []string) error {
 
 func init() {
 	Cmd.Flags().BoolP("refetch", "r", false, "refetch env vars from cluster")
+	Cmd.Flags().String("shell", "", "shell to start, defaults to the shell setting or $SHELL")
+	fn.WithProfile(Cmd)
 }
@@ -0,0 +1,105 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/env"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <from> [to]",
+	Short: "compare the env of the target with the cache, the cluster or another workload",
+	Long: `Compare two envs, each one is either:
+
+  cache     the cached env of the target, as inkube dev would use it offline
+  cluster   the env of the target read from the cluster now
+  dev       the env of the dev shell, with the overrides and the devbox env
+  [namespace/][kind/]name[:container,...]
+            the env of other containers read from the cluster, merged in
+            order, the parts left out are the ones of the target
+
+loadEnv.include, loadEnv.exclude and loadEnv.unset of the target apply to every
+side. to defaults to dev. Values read from Secrets, and values that look like secrets,
+are redacted unless --reveal is passed.`,
+	Example: `  inkube env diff cache cluster
+  inkube env diff staging/api:api
+  inkube env diff cluster dev -o json`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runDiff(cmd, args); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	cfg := config.Singleton()
+	t, err := cfg.Target()
+	if err != nil {
+		return err
+	}
+
+	to := env.SideDev
+	if len(args) == 2 {
+		to = args[1]
+	}
+
+	a, err := env.Load(cfg, t, args[0])
+	if err != nil {
+		return err
+	}
+
+	b, err := env.Load(cfg, t, to)
+	if err != nil {
+		return err
+	}
+
+	d := env.Compare(a.Vars, b.Vars)
+	if !flags.Reveal {
+		var n int
+		if d, n = d.Redact(a, b); n > 0 {
+			fn.Log(text.Yellow(fmt.Sprintf("[!] %d secret values are redacted, pass --reveal to print them", n)))
+		}
+	}
+
+	if fn.ParseStringFlag(cmd, "output") == "json" {
+		b, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+		fn.Println(string(b))
+		return nil
+	}
+
+	if d.Empty() {
+		fn.Log(text.Blue(fmt.Sprintf("[#] %s and %s are the same", args[0], to)))
+		return nil
+	}
+
+	fn.Println(text.Bold(fmt.Sprintf("--- %s\n+++ %s", args[0], to)))
+	for _, k := range d.Keys() {
+		if v, ok := d.Removed[k]; ok {
+			fn.Println(text.Red(fmt.Sprintf("- %s=%s", k, v)))
+		} else if v, ok := d.Added[k]; ok {
+			fn.Println(text.Green(fmt.Sprintf("+ %s=%s", k, v)))
+		} else {
+			c := d.Changed[k]
+			fn.Println(text.Yellow(fmt.Sprintf("~ %s: %s -> %s", k, c.From, c.To)))
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	diffCmd.Flags().StringP("output", "o", "text", "output format [text | json]")
+	fn.WithProfile(diffCmd)
+
+	Cmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,89 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/env"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "env",
+	Short: "print the env of the dev shell, for IDE run configs, docker --env-file or CI jobs",
+	Long: `Print the env inkube dev would start the shell with: the env of the containers
+read from the cluster, the overrides and the devbox env.
+
+Values read from Secrets, and values that look like secrets, are redacted
+unless --reveal is passed.`,
+	Example: `  inkube env > .env
+  inkube env -o docker --reveal > app.env && docker run --env-file app.env app
+  eval "$(inkube env -o shell --reveal)"
+  inkube env --only-cluster --keys 'DB_*,REDIS_URL' -o json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := Run(cmd, args); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func Run(cmd *cobra.Command, _ []string) error {
+	format := env.Format(fn.ParseStringFlag(cmd, "output"))
+
+	cfg := config.Singleton()
+	t, err := cfg.Target()
+	if err != nil {
+		return err
+	}
+
+	if !t.LoadEnv.Enabled {
+		fn.Log(text.Yellow("[!] loadEnv is disabled, the env of the cluster is left out"))
+	}
+
+	e, err := env.Build(cfg, t, env.Options{
+		Refetch:     fn.ParseBoolFlag(cmd, "refetch"),
+		OnlyCluster: fn.ParseBoolFlag(cmd, "only-cluster"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if keys, _ := cmd.Flags().GetStringSlice("keys"); len(keys) > 0 {
+		if err := e.Only(keys); err != nil {
+			return err
+		}
+	}
+
+	vars := e.Vars
+	if !flags.Reveal {
+		var n int
+		if vars, n = e.Redact(); n > 0 {
+			fn.Log(text.Yellow(fmt.Sprintf("[!] %d secret values are redacted, pass --reveal to print them", n)))
+		}
+	}
+
+	out, skipped, err := format.Format(vars)
+	if err != nil {
+		return err
+	}
+
+	if len(skipped) > 0 {
+		fn.Log(text.Yellow("[!] multi-line values can't be written to a docker env-file, left out: " + strings.Join(skipped, ", ")))
+	}
+
+	fn.Printf("%s", out)
+	return nil
+}
+
+func init() {
+	Cmd.Flags().StringP("output", "o", string(env.FormatDotenv), "output format [dotenv | shell | fish | json | yaml | docker]")
+	Cmd.Flags().Bool("only-cluster", false, "only print the env read from the cluster, without the overrides and devbox")
+	Cmd.Flags().StringSlice("keys", nil, "only print these vars, glob patterns like DB_* are allowed")
+	Cmd.Flags().BoolP("refetch", "r", false, "refetch env vars from cluster")
+	fn.WithProfile(Cmd)
+}
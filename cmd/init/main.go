@@ -13,7 +13,6 @@ import (
 	"github.com/abdheshnayak/inkube/pkg/ui/spinner"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
-	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -62,22 +61,29 @@ func run() error {
 		return err
 	}
 
-	dl, err := kubeClient.Clientset.AppsV1().Deployments(ns.Name).List(kubeClient.Ctx(), v1.ListOptions{})
+	f = spinner.Client.UpdateMessage("fetching workloads")
+	wl, err := kubeClient.ListWorkloads(kubeClient.Ctx(), ns.Name)
+	f()
 	if err != nil {
 		return err
 	}
 
-	dep, err := fzf.FindOne(dl.Items, func(item appv1.Deployment) string {
-		return item.Name
-	}, fzf.WithPrompt("select deployment"))
+	if len(wl) == 0 {
+		return fn.Errorf("no workloads found in namespace %s", ns.Name)
+	}
+
+	w, err := fzf.FindOne(wl, func(item kube.Workload) string {
+		return item.String()
+	}, fzf.WithPrompt("select workload"))
 
 	if err != nil {
 		return err
 	}
 
-	cont, err := fzf.FindOne(dep.Spec.Template.Spec.Containers, func(item corev1.Container) string {
-		return item.Name
-	}, fzf.WithPrompt("select container"))
+	// picked in merge order, init containers are listed as init:<name>
+	containers, err := fzf.FindMany(w.ContainerNames(), func(item string) string {
+		return item
+	}, fzf.WithPrompt("select containers, tab to pick several"))
 	if err != nil {
 		return err
 	}
@@ -102,19 +108,22 @@ func run() error {
 	}
 
 	b, err := yaml.Marshal(config.Config{
-		Connect:   true,
-		Version:   "v1",
-		Namespace: ns.Name,
-		LoadEnv: config.LoadEnv{
-			Container: cont.Name,
-			Enabled:   true,
-			Overrides: map[string]string{
-				"INKUBE": "true",
+		Connect: true,
+		Version: config.CurrentVersion(),
+		Target: config.Target{
+			Namespace: ns.Name,
+			LoadEnv: config.LoadEnv{
+				Containers: containers,
+				Enabled:    true,
+				Overrides: map[string]string{
+					"INKUBE": "true",
+				},
+			},
+			Bridge: config.BridgeConfig{
+				Name:      w.Name,
+				Kind:      string(w.Kind),
+				Intercept: false,
 			},
-		},
-		Bridge: config.BridgeConfig{
-			Name:      dep.Name,
-			Intercept: false,
 		},
 		Devbox: true,
 	})
@@ -123,10 +132,10 @@ func run() error {
 		return err
 	}
 
-	cfgPath := path.Join(cwd, "inkube.yaml")
+	cfgPath := path.Join(cwd, config.FileName)
 	if err := os.WriteFile(cfgPath, b, 0o644); err != nil {
 		return err
 	}
 
-	return devbox.NewDevboxClient().EnsureInit()
+	return devbox.NewDevboxClient().EnsureInit(cwd)
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/abdheshnayak/inkube/pkg/config"
 	"github.com/abdheshnayak/inkube/pkg/connect"
 	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
 	"github.com/spf13/cobra"
 )
 
@@ -20,23 +21,31 @@ var Cmd = &cobra.Command{
 func Run(_ *cobra.Command, args []string) error {
 
 	cfg := config.Singleton()
+	t, err := cfg.Target()
+	if err != nil {
+		return err
+	}
 
-	please := "please run `inkube switch` to set the app name, namespace and container"
-	if cfg.Bridge.Name == "" {
-		return fn.Errorf("deployment name is not set, %s", please)
+	please := "please run `inkube switch` to set the app name, namespace and containers"
+	if t.Bridge.Name == "" {
+		return fn.Errorf("workload name is not set, %s", please)
 	}
 
-	if cfg.LoadEnv.Container == "" {
-		return fn.Errorf("container is not set, %s", please)
+	if len(t.LoadEnv.Containers) == 0 {
+		return fn.Errorf("containers are not set, %s", please)
 	}
 
-	if cfg.Namespace == "" {
+	if t.Namespace == "" {
 		return fn.Errorf("namespace is not set, %s", please)
 	}
 
-	if err := connect.SClient().Leave(cfg.Bridge.Name, cfg.Namespace); err != nil {
+	if err := connect.SClient().Leave(kube.WorkloadRef{Kind: kube.WorkloadKind(t.Bridge.Kind), Name: t.Bridge.Name}, t.Namespace); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func init() {
+	fn.WithProfile(Cmd)
+}
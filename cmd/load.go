@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"github.com/abdheshnayak/inkube/cmd/cache"
+	"github.com/abdheshnayak/inkube/cmd/config"
 	"github.com/abdheshnayak/inkube/cmd/connect"
 	"github.com/abdheshnayak/inkube/cmd/dev"
 	"github.com/abdheshnayak/inkube/cmd/disconnect"
+	"github.com/abdheshnayak/inkube/cmd/env"
 	i "github.com/abdheshnayak/inkube/cmd/init"
 	"github.com/abdheshnayak/inkube/cmd/intercept"
 	"github.com/abdheshnayak/inkube/cmd/leave"
 	"github.com/abdheshnayak/inkube/cmd/quit"
+	"github.com/abdheshnayak/inkube/cmd/secrets"
 	"github.com/abdheshnayak/inkube/cmd/status"
 	sw "github.com/abdheshnayak/inkube/cmd/switch"
 	"github.com/abdheshnayak/inkube/flags"
@@ -19,6 +23,7 @@ func Load(root *cobra.Command) {
 	root.CompletionOptions.HiddenDefaultCmd = true
 
 	root.AddCommand(dev.Cmd)
+	root.AddCommand(env.Cmd)
 	root.AddCommand(i.Cmd)
 	root.AddCommand(sw.Cmd)
 	root.AddCommand(status.Cmd)
@@ -30,6 +35,10 @@ func Load(root *cobra.Command) {
 	root.AddCommand(connect.Cmd)
 	root.AddCommand(disconnect.Cmd)
 
+	root.AddCommand(config.Cmd)
+	root.AddCommand(cache.Cmd)
+	root.AddCommand(secrets.Cmd)
+
 	Init(root)
 }
 
@@ -40,4 +49,11 @@ func Init(root *cobra.Command) {
 		c.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 		c.PersistentFlags().BoolP("quiet", "q", false, "quiet output")
 	}
+
+	root.PersistentFlags().String("backend", "", "tool used to connect to the cluster [kubevpn | telepresence]")
+	root.PersistentFlags().String("context", "", "kube context to use, overrides the one set in inkube.yaml")
+	root.PersistentFlags().String("kubeconfig", "", "kubeconfig file to use, overrides the one set in inkube.yaml")
+	root.PersistentFlags().String("config", "", "path to inkube.yaml, by default it is searched from the current directory up")
+	root.PersistentFlags().Bool("no-cache", false, "keep the env vars read from the cluster in memory only")
+	root.PersistentFlags().Bool("reveal", false, "print secret values instead of redacting them")
 }
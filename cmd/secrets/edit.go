@@ -0,0 +1,188 @@
+package secrets
+
+import (
+	"bytes"
+	"cmp"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/abdheshnayak/inkube/pkg/env"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+	"github.com/abdheshnayak/inkube/pkg/secrets"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"github.com/spf13/cobra"
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <file>",
+	Short: "open a decrypted copy of an encrypted file in $EDITOR and encrypt it again on save",
+	Long: `Open a decrypted copy of an encrypted file in $VISUAL or $EDITOR, the file is
+encrypted again once the editor exits, if it changed. The file is created if
+it doesn't exist.
+
+The recipients are the ones of --recipient and --recipients-file, or of the
+first creation rule of the nearest .sops.yaml matching the file. SOPS files
+keep their recipients, the new ones are added. New files are encrypted for
+your own key when no recipient is given.`,
+	Example: `  inkube secrets edit secrets.enc.yaml
+  inkube secrets edit .env.shared.age -R .age-recipients
+  inkube secrets edit secrets.enc.yaml -r age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runEdit(cmd, args[0]); err != nil {
+			fn.PrintError(err)
+		}
+	},
+}
+
+func runEdit(cmd *cobra.Command, file string) error {
+	if !secrets.IsEncrypted(file) {
+		return fn.Errorf("%s is not an encrypted file, expected a name ending in .enc.yaml or .age", file)
+	}
+
+	old, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return fn.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var plain []byte
+	if old != nil {
+		if plain, err = secrets.Decrypt(file, old); err != nil {
+			return err
+		}
+	}
+
+	rs, err := recipients(cmd, file, old == nil)
+	if err != nil {
+		return err
+	}
+
+	// the decrypted copy lives in a directory only the user can read, on a
+	// tmpfs when there is one, with the plain name so that editors pick the
+	// right syntax. It is removed on SIGINT too, deferred calls don't run then.
+	dir, err := kube.MountRoot()
+	if err != nil {
+		return err
+	}
+	fn.OnCleanup(func() {
+		os.RemoveAll(dir)
+	})
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, secrets.Plain(filepath.Base(file)))
+	if err := os.WriteFile(tmp, plain, 0o600); err != nil {
+		return err
+	}
+
+	for {
+		if err := edit(tmp); err != nil {
+			return err
+		}
+
+		b, err := os.ReadFile(tmp)
+		if err != nil {
+			return err
+		}
+
+		if old != nil && bytes.Equal(b, plain) {
+			fn.Log(text.Blue("[#] no changes, " + file + " is left as is"))
+			return nil
+		}
+
+		out, err := encrypt(file, b, old, rs)
+		if err == nil {
+			if err := write(file, out); err != nil {
+				return err
+			}
+			fn.Log(text.Blue("[#] " + file + " is encrypted"))
+			return nil
+		}
+
+		fn.Log(text.Yellow("[!] " + err.Error()))
+		// no by default, a closed stdin would loop forever
+		fn.Logf("edit it again? [y/N] ")
+		if !fn.Confirm("y", "") {
+			return fn.Errorf("%s is left as is", file)
+		}
+	}
+}
+
+// recipients returns the recipients of the flags or of .sops.yaml, or the
+// own ones of the user for a new file.
+func recipients(cmd *cobra.Command, file string, isNew bool) ([]*age.X25519Recipient, error) {
+	ss, _ := cmd.Flags().GetStringArray("recipient")
+
+	if f := fn.ParseStringFlag(cmd, "recipients-file"); f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fn.Errorf("failed to read the recipients file: %w", err)
+		}
+		ss = append(ss, strings.Split(string(b), "\n")...)
+	}
+
+	rs, err := secrets.ParseRecipients(ss...)
+	if err != nil || len(rs) > 0 {
+		return rs, err
+	}
+
+	if rs, err = secrets.Recipients(file); err != nil || len(rs) > 0 {
+		return rs, err
+	}
+
+	if isNew {
+		ids, err := secrets.Identities()
+		if err != nil {
+			return nil, err
+		}
+		fn.Log(text.Yellow("[!] no recipients given, " + file + " is encrypted for your own key only"))
+		return secrets.Own(ids), nil
+	}
+
+	if !strings.HasSuffix(file, ".age") {
+		return nil, nil
+	}
+
+	return nil, fn.Errorf("age files don't record their recipients, pass --recipient or --recipients-file, or add a creation rule to .sops.yaml")
+}
+
+// encrypt checks that the edited file parses like loadEnv.overridesFrom
+// reads it, then encrypts it.
+func encrypt(file string, b, old []byte, rs []*age.X25519Recipient) ([]byte, error) {
+	if _, err := env.ParseOverrides(file, b); err != nil {
+		return nil, fn.Errorf("invalid %s: %w", secrets.Plain(filepath.Base(file)), err)
+	}
+
+	return secrets.Encrypt(file, b, old, rs)
+}
+
+func edit(file string) error {
+	editor := strings.Fields(cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi"))
+
+	c := exec.Command(editor[0], append(editor[1:], file)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fn.Errorf("%s failed: %w", editor[0], err)
+	}
+	return nil
+}
+
+// write replaces file, keeping its mode.
+func write(file string, b []byte) error {
+	mode := os.FileMode(0o644)
+	if s, err := os.Stat(file); err == nil {
+		mode = s.Mode().Perm()
+	}
+
+	return fn.WriteFileAtomic(file, b, mode)
+}
+
+func init() {
+	editCmd.Flags().StringArrayP("recipient", "r", nil, "age public key to encrypt for, can be repeated")
+	editCmd.Flags().StringP("recipients-file", "R", "", "file of age public keys to encrypt for, one per line")
+
+	Cmd.AddCommand(editCmd)
+}
@@ -0,0 +1,17 @@
+package secrets
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "edit the encrypted files of loadEnv.overridesFrom",
+	Long: `Edit the files of loadEnv.overridesFrom committed encrypted:
+
+  name.age        a dotenv file, or a YAML file for name.yaml.age, encrypted with age
+  name.enc.yaml   a YAML file encrypted with SOPS and age
+
+They are decrypted with the age identities of $SOPS_AGE_KEY, and of
+$SOPS_AGE_KEY_FILE or ~/.config/sops/age/keys.txt, like SOPS does.`,
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/abdheshnayak/inkube/pkg/ui/fzf"
 	"github.com/abdheshnayak/inkube/pkg/ui/spinner"
 	"github.com/spf13/cobra"
-	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -18,22 +17,34 @@ var Cmd = &cobra.Command{
 	Use:   "switch",
 	Short: "switch the app, you are working on",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := run(); err != nil {
+		if err := run(cmd); err != nil {
 			fn.PrintError(err)
 		}
 	},
 }
 
-func run() error {
+func run(cmd *cobra.Command) error {
 	s, ok := os.LookupEnv("INKUBE")
 	if ok && s == "true" {
 		return fn.Error("you are already in inkube session, please exit the session first")
 	}
 
-	kubeClient := kube.Singleton()
-
 	cfg := config.Singleton()
 
+	// switching to an existing profile only moves the active pointer, the
+	// pickers below are used to create new profiles
+	profile := fn.ParseStringFlag(cmd, "profile")
+	if profile != "" {
+		if _, err := cfg.GetProfile(profile); err == nil {
+			cfg.Active = profile
+			return cfg.Write()
+		}
+	} else {
+		profile = cfg.Profile()
+	}
+
+	kubeClient := kube.Singleton()
+
 	f := spinner.Client.UpdateMessage("fetching namespaces")
 	nl, err := kubeClient.Clientset.CoreV1().Namespaces().List(kubeClient.Ctx(), v1.ListOptions{})
 	f()
@@ -52,32 +63,55 @@ func run() error {
 		return err
 	}
 
-	dl, err := kubeClient.Clientset.AppsV1().Deployments(ns.Name).List(kubeClient.Ctx(), v1.ListOptions{})
+	f = spinner.Client.UpdateMessage("fetching workloads")
+	wl, err := kubeClient.ListWorkloads(kubeClient.Ctx(), ns.Name)
+	f()
 	if err != nil {
 		return err
 	}
 
-	dep, err := fzf.FindOne(dl.Items, func(item appv1.Deployment) string {
-		return item.Name
-	}, fzf.WithPrompt("select deployment"))
+	if len(wl) == 0 {
+		return fn.Errorf("no workloads found in namespace %s", ns.Name)
+	}
+
+	w, err := fzf.FindOne(wl, func(item kube.Workload) string {
+		return item.String()
+	}, fzf.WithPrompt("select workload"))
 
 	if err != nil {
 		return err
 	}
 
-	cont, err := fzf.FindOne(dep.Spec.Template.Spec.Containers, func(item corev1.Container) string {
-		return item.Name
-	}, fzf.WithPrompt("select container"))
+	// picked in merge order, init containers are listed as init:<name>
+	containers, err := fzf.FindMany(w.ContainerNames(), func(item string) string {
+		return item
+	}, fzf.WithPrompt("select containers, tab to pick several"))
 	if err != nil {
 		return err
 	}
 
+	t := config.Target{}
+	if curr, err := cfg.GetProfile(profile); err == nil {
+		t = *curr
+	}
+
+	t.Namespace = ns.Name
+	t.Bridge.Intercept = false
+	t.Bridge.Name = w.Name
+	t.Bridge.Kind = string(w.Kind)
+
+	t.LoadEnv.Containers = containers
+	t.LoadEnv.Enabled = true
+
 	cfg.Connect = true
-	cfg.Namespace = ns.Name
-	cfg.Bridge.Intercept = false
-	cfg.Bridge.Name = dep.Name
+	cfg.SetProfile(profile, t)
+	if cfg.Active != "" || profile != config.DefaultProfile {
+		cfg.Active = profile
+	}
 
-	cfg.LoadEnv.Container = cont.Name
-	cfg.LoadEnv.Enabled = true
 	return cfg.Write()
 }
+
+func init() {
+	fn.WithProfile(Cmd)
+}
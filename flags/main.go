@@ -3,6 +3,7 @@ package flags
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/adrg/xdg"
 )
@@ -14,8 +15,43 @@ var (
 	IsVerbose = false
 	IsQuiet   = false
 
+	// Reveal prints secret values instead of redacting them, see
+	// fn.Redact.
+	Reveal = false
+
+	// Profile selects a named target from inkube.yaml for this invocation.
+	Profile = ""
+
+	// ConfigPath points to the inkube.yaml to use instead of discovering it.
+	ConfigPath = ""
+
+	// KubeContext and Kubeconfig pin the cluster inkube talks to, they come
+	// from the target in inkube.yaml or --context and --kubeconfig. Empty
+	// means the current context of the default kubeconfig.
+	KubeContext = ""
+	Kubeconfig  = ""
+
 	CacheHome = xdg.CacheHome
 	CacheDir  = fmt.Sprintf("%s/inkube", CacheHome)
+
+	ConfigHome = xdg.ConfigHome
+	ConfigDir  = fmt.Sprintf("%s/inkube", ConfigHome)
+
+	DataHome = xdg.DataHome
+	DataDir  = fmt.Sprintf("%s/inkube", DataHome)
+)
+
+// Settings resolved from flags, env, the project and the user config, see
+// config.Apply. The values here are the built-in defaults.
+var (
+	Backend          = "kubevpn"
+	ManagerNamespace = ""
+	Shell            = ""
+	Prompt           = true
+	Notifications    = true
+	Sound            = true
+	Cache            = true
+	CacheTTL         = 24 * time.Hour
 )
 
 func IsDev() bool {
@@ -26,6 +62,6 @@ func IsDev() bool {
 }
 
 func GetCacheDir() string {
-	os.MkdirAll(CacheDir, 0755)
+	os.MkdirAll(CacheDir, 0700)
 	return CacheDir
 }
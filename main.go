@@ -7,6 +7,7 @@ import (
 
 	"github.com/abdheshnayak/inkube/cmd"
 	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/config"
 	"github.com/abdheshnayak/inkube/pkg/connect"
 	"github.com/abdheshnayak/inkube/pkg/devbox"
 	fn "github.com/abdheshnayak/inkube/pkg/fn"
@@ -25,6 +26,57 @@ var rootCmd = &cobra.Command{
 			flags.DevMode = "false"
 		}
 
+		if s, ok := os.LookupEnv("INKUBE_CONFIG"); ok {
+			flags.ConfigPath = s
+		}
+
+		if c := fn.ParseStringFlag(cmd, "config"); c != "" {
+			flags.ConfigPath = c
+		}
+
+		if s, ok := os.LookupEnv("INKUBE_PROFILE"); ok {
+			flags.Profile = s
+		}
+
+		if p := fn.ParseStringFlag(cmd, "profile"); p != "" {
+			flags.Profile = p
+		}
+
+		if err := config.Apply(); err != nil {
+			fn.PrintError(err)
+			os.Exit(1)
+		}
+
+		if b := fn.ParseStringFlag(cmd, "backend"); b != "" {
+			flags.Backend = b
+		}
+
+		if c := fn.ParseStringFlag(cmd, "context"); c != "" {
+			flags.KubeContext = c
+		}
+
+		if k := fn.ParseStringFlag(cmd, "kubeconfig"); k != "" {
+			flags.Kubeconfig = k
+		}
+
+		if fn.ParseBoolFlag(cmd, "no-cache") {
+			flags.Cache = false
+		}
+
+		if fn.ParseBoolFlag(cmd, "reveal") {
+			flags.Reveal = true
+		}
+
+		if flags.Backend != connect.BackendKubeVpn && flags.Backend != connect.BackendTelepresence {
+			fn.PrintError(fn.Errorf("unknown backend %q, expected %s or %s", flags.Backend, connect.BackendKubeVpn, connect.BackendTelepresence))
+			os.Exit(1)
+		}
+
+		if err := ensureDependencies(); err != nil {
+			fn.PrintError(err)
+			os.Exit(1)
+		}
+
 		verbose := fn.ParseBoolFlag(cmd, "verbose")
 		if verbose {
 			spinner.Client.SetVerbose(verbose)
@@ -44,6 +96,7 @@ var rootCmd = &cobra.Command{
 			<-sigChan
 
 			spinner.Client.Stop()
+			fn.Cleanup()
 			os.Exit(1)
 		}()
 	},
@@ -54,22 +107,26 @@ var rootCmd = &cobra.Command{
 }
 
 func main() {
-	if err := Run(); err != nil {
+	err := Run()
+	fn.Cleanup()
+
+	if err != nil {
 		fn.PrintError(err)
 		os.Exit(1)
 	}
 }
 
-func Run() error {
-
+// ensureDependencies checks the tools inkube shells out to, it runs once the
+// settings are known as they pick the connect backend.
+func ensureDependencies() error {
 	if err := devbox.NewDevboxClient().EnsureDependencies(); err != nil {
 		return err
 	}
 
-	if err := connect.SClient().EnsureDependencies(); err != nil {
-		return err
-	}
+	return connect.SClient().EnsureDependencies()
+}
 
+func Run() error {
 	cmd.Load(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
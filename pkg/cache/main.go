@@ -0,0 +1,275 @@
+// Package cache stores data that is expensive to fetch, like the resolved env
+// of a container, encrypted under the cache directory.
+//
+// Files are sealed with AES-256-GCM using a random per-user key kept in a
+// 0600 file outside of the cache directory, and start with a header:
+//
+//	"INKC" | version (1 byte) | nonce (12 bytes) | ciphertext
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/egob"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+const (
+	// Version of the file format, files of other versions are ignored.
+	Version byte = 1
+
+	keyFileName = "cache.key"
+	keySize     = 32
+)
+
+var magic = []byte("INKC")
+
+var (
+	// ErrDisabled is returned when caching is turned off, see flags.Cache.
+	ErrDisabled = errors.New("cache is disabled")
+
+	// ErrFormat is returned for files that aren't cache files of this
+	// version, they are overwritten on the next write.
+	ErrFormat = errors.New("unsupported cache file format")
+)
+
+// KeyPath is the file holding the key cache files are encrypted with.
+func KeyPath() string {
+	return filepath.Join(flags.DataDir, keyFileName)
+}
+
+// Path is the path of the cache file name.
+func Path(name string) string {
+	return filepath.Join(flags.GetCacheDir(), name)
+}
+
+var (
+	aead     cipher.AEAD
+	aeadErr  error
+	aeadOnce sync.Once
+)
+
+func getAEAD() (cipher.AEAD, error) {
+	aeadOnce.Do(func() {
+		var key []byte
+		key, aeadErr = loadKey()
+		if aeadErr != nil {
+			return
+		}
+
+		var block cipher.Block
+		block, aeadErr = aes.NewCipher(key)
+		if aeadErr != nil {
+			return
+		}
+
+		aead, aeadErr = cipher.NewGCM(block)
+	})
+
+	return aead, aeadErr
+}
+
+// loadKey reads the key, or creates it on first use.
+func loadKey() ([]byte, error) {
+	p := KeyPath()
+
+	key, err := os.ReadFile(p)
+	if err == nil {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("cache key %s is corrupted, remove it to reset the cache", p)
+		}
+		return key, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return nil, err
+	}
+
+	// O_EXCL, a concurrent inkube may have created it in the meantime
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if os.IsExist(err) {
+			return loadKey()
+		}
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		os.Remove(p)
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(p)
+		return nil, err
+	}
+
+	// the cache was written in plain text before it was encrypted
+	removePlain()
+
+	return key, nil
+}
+
+// removePlain removes cache files written without encryption.
+func removePlain() {
+	files, _ := filepath.Glob(filepath.Join(flags.CacheDir, "*.secret.cache"))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err == nil && !bytes.HasPrefix(b, magic) {
+			os.Remove(f)
+		}
+	}
+}
+
+// Seal encrypts b into the cache file format.
+func Seal(b []byte) ([]byte, error) {
+	a, err := getAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, a.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	// the magic and version are authenticated too
+	aad := append(bytes.Clone(magic), Version)
+	header := append(bytes.Clone(aad), nonce...)
+
+	return a.Seal(header, nonce, b, aad), nil
+}
+
+// Unseal decrypts b sealed by Seal.
+func Unseal(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, magic) || len(b) < len(magic)+1 || b[len(magic)] != Version {
+		return nil, ErrFormat
+	}
+
+	a, err := getAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	header := len(magic) + 1
+	if len(b) < header+a.NonceSize() {
+		return nil, ErrFormat
+	}
+
+	nonce := b[header : header+a.NonceSize()]
+	plain, err := a.Open(nil, nonce, b[header+a.NonceSize():], b[:header])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the cache, it was written with another key: %w", err)
+	}
+
+	return plain, nil
+}
+
+// Read decodes the cache file name into v.
+func Read(name string, v any) error {
+	if !flags.Cache {
+		return ErrDisabled
+	}
+
+	return Inspect(name, v)
+}
+
+// Inspect decodes the cache file name into v like Read, even when caching
+// is disabled, for `inkube cache`.
+func Inspect(name string, v any) error {
+	b, err := os.ReadFile(Path(name))
+	if err != nil {
+		return err
+	}
+
+	plain, err := Unseal(b)
+	if err != nil {
+		return err
+	}
+
+	return egob.Unmarshal(plain, v)
+}
+
+// Write encodes v into the cache file name, readable by the user only.
+func Write(name string, v any) error {
+	if !flags.Cache {
+		return nil
+	}
+
+	b, err := egob.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := Seal(b)
+	if err != nil {
+		return err
+	}
+
+	return fn.WriteFileAtomic(Path(name), sealed, 0o600)
+}
+
+// File is a file of the cache directory.
+type File struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// List returns the files of the cache directory, oldest first.
+func List() ([]File, error) {
+	entries, err := os.ReadDir(flags.GetCacheDir())
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(entries))
+	for _, e := range entries {
+		// temporary files of writeFile
+		if e.IsDir() || e.Name()[0] == '.' {
+			continue
+		}
+
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, File{Name: e.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.Before(files[j].ModTime)
+	})
+
+	return files, nil
+}
+
+// Remove removes the cache file name, it is not an error if it doesn't
+// exist.
+func Remove(name string) error {
+	if err := os.Remove(Path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"os"
+	"testing"
+
+	"github.com/abdheshnayak/inkube/flags"
+)
+
+func TestCache(t *testing.T) {
+	flags.DataDir = t.TempDir()
+	flags.CacheDir = t.TempDir()
+
+	// written before the cache was encrypted
+	plain := Path("ns-deployment-api-api-spec.secret.cache")
+	if err := os.WriteFile(plain, []byte("PASSWORD=hunter2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"PASSWORD": "hunter2"}
+	if err := Write("envs.secret.cache", want); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(plain); !os.IsNotExist(err) {
+		t.Errorf("plain text cache files should be removed when the key is created")
+	}
+
+	for _, p := range []string{KeyPath(), Path("envs.secret.cache")} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o600 {
+			t.Errorf("%s: got mode %o, want 600", p, fi.Mode().Perm())
+		}
+	}
+
+	b, err := os.ReadFile(Path("envs.secret.cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(b, append([]byte("INKC"), Version)) {
+		t.Errorf("missing header: %q", b[:5])
+	}
+
+	if bytes.Contains(b, []byte("hunter2")) {
+		t.Errorf("cache file holds the secret in plain text")
+	}
+
+	got := map[string]string{}
+	if err := Read("envs.secret.cache", &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := bytes.Clone(b)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := Unseal(tampered); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("other version", func(t *testing.T) {
+		other := bytes.Clone(b)
+		other[4] = Version + 1
+		if _, err := Unseal(other); !errors.Is(err, ErrFormat) {
+			t.Errorf("got %v, want ErrFormat", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		flags.Cache = false
+		defer func() { flags.Cache = true }()
+
+		if err := Read("envs.secret.cache", &got); !errors.Is(err, ErrDisabled) {
+			t.Errorf("got %v, want ErrDisabled", err)
+		}
+
+		if err := Write("other.secret.cache", want); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(Path("other.secret.cache")); !os.IsNotExist(err) {
+			t.Errorf("nothing should be written when the cache is disabled")
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	flags.DataDir = t.TempDir()
+	flags.CacheDir = t.TempDir()
+
+	for _, name := range []string{"a.secret.cache", "b.secret.cache"} {
+		if err := Write(name, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// left behind by an interrupted write
+	if err := os.WriteFile(Path(".a.secret.cache.123"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("got %v, want the two cache files", files)
+	}
+
+	if err := Remove("a.secret.cache"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Remove("a.secret.cache"); err != nil {
+		t.Errorf("removing a missing file: %v", err)
+	}
+
+	if files, _ := List(); len(files) != 1 || files[0].Name != "b.secret.cache" {
+		t.Errorf("got %v, want b.secret.cache", files)
+	}
+}
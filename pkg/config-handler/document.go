@@ -0,0 +1,90 @@
+package confighandler
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a yaml file edited in place, keys keep their order, comments
+// are kept and anything not touched is written back as it was read.
+type Document struct {
+	doc *yaml.Node
+}
+
+func ParseDocument(b []byte) (*Document, error) {
+	doc, err := parseNode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{doc: doc}, nil
+}
+
+func ReadDocument(path string) (*Document, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDocument(b)
+}
+
+func (d *Document) Get(keys ...string) (any, bool) {
+	n, ok := lookup(d.doc, keys)
+	if !ok {
+		return nil, false
+	}
+
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
+
+func (d *Document) GetString(keys ...string) string {
+	n, ok := lookup(d.doc, keys)
+	if !ok || n.Kind != yaml.ScalarNode || n.ShortTag() == "!!null" {
+		return ""
+	}
+
+	return n.Value
+}
+
+func (d *Document) Set(value any, keys ...string) error {
+	n, err := toNode(value)
+	if err != nil {
+		return err
+	}
+
+	setIn(d.doc, keys, n)
+	return nil
+}
+
+func (d *Document) Unset(keys ...string) bool {
+	return unsetIn(d.doc, keys)
+}
+
+// Rename renames the last of keys to name, keeping its value, comments and
+// position.
+func (d *Document) Rename(name string, keys ...string) bool {
+	return renameIn(d.doc, keys, name)
+}
+
+func (d *Document) Bytes() ([]byte, error) {
+	return encodeNode(d.doc)
+}
+
+// Write atomically replaces the file at path with the document.
+func (d *Document) Write(path string) error {
+	b, err := d.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return withLock(path, func() error {
+		return writeFile(path, b, 0o644)
+	})
+}
@@ -0,0 +1,173 @@
+package confighandler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const commented = `# inkube config
+version: v2
+namespace: ${USER}-dev # one per developer
+bridge:
+  # the api server
+  name: api
+  kind: "deployment"
+loadEnv:
+  overrides:
+    IMAGE: ${REGISTRY:-ghcr.io}/api
+    LITERAL: $${NOT_EXPANDED}
+# trailing comment
+`
+
+func TestDocumentSet(t *testing.T) {
+	d, err := ParseDocument([]byte(commented))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []struct {
+		value any
+		keys  []string
+	}{
+		{"${USER}-staging", []string{"namespace"}},
+		{"worker", []string{"bridge", "name"}},
+		{"statefulset", []string{"bridge", "kind"}},
+		{"1", []string{"loadEnv", "overrides", "DEBUG"}},
+		{true, []string{"loadEnv", "enabled"}},
+	} {
+		if err := d.Set(s.value, s.keys...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# inkube config
+version: v2
+namespace: ${USER}-staging # one per developer
+bridge:
+  # the api server
+  name: worker
+  kind: "statefulset"
+loadEnv:
+  overrides:
+    IMAGE: ${REGISTRY:-ghcr.io}/api
+    LITERAL: $${NOT_EXPANDED}
+    DEBUG: "1"
+  enabled: true
+# trailing comment
+`
+	if string(b) != want {
+		t.Errorf("got\n%s\nwant\n%s", b, want)
+	}
+}
+
+func TestDocumentUnsetRename(t *testing.T) {
+	d, err := ParseDocument([]byte(commented))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Unset("loadEnv", "overrides", "IMAGE") || !d.Unset("loadEnv", "overrides", "LITERAL") {
+		t.Fatal("failed to unset the overrides")
+	}
+	if d.Unset("loadEnv", "overrides", "IMAGE") {
+		t.Error("unset a key that isn't there")
+	}
+
+	// maps left empty are removed
+	if _, ok := d.Get("loadEnv"); ok {
+		t.Error("loadEnv is still there")
+	}
+
+	if !d.Rename("service", "bridge", "name") {
+		t.Fatal("failed to rename bridge.name")
+	}
+	if got := d.GetString("bridge", "service"); got != "api" {
+		t.Errorf("got bridge.service %q, want api", got)
+	}
+
+	b, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "  # the api server\n  service: api\n") {
+		t.Errorf("the comment of bridge.name was lost:\n%s", b)
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("# settings\nname: old # the name\nextra: kept\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteConfig(path, testConfig{Name: "new", Namespace: "ns"}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# settings\nname: new # the name\nextra: kept\nnamespace: ns\n"
+	if got := readFile(t, path); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// the mode of the file is kept
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("got mode %o, want 600", fi.Mode().Perm())
+	}
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "config.yaml" {
+			t.Errorf("unexpected file %s", e.Name())
+		}
+	}
+}
+
+func TestLayersExpand(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"inkube.yaml": "name: api\nimage: ${REGISTRY}/api # pushed by CI\n",
+	})
+
+	h := GetLayeredHandler[testConfig](filepath.Join(dir, "inkube.yaml"),
+		WithExpand(func(s string) (string, error) {
+			return strings.ReplaceAll(s, "${REGISTRY}", "ghcr.io"), nil
+		}),
+	)
+
+	cfg, err := h.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Image != "ghcr.io/api" {
+		t.Errorf("got image %q, want ghcr.io/api", cfg.Image)
+	}
+
+	// writes keep the template, only the changed key is written
+	cfg.Name = "worker"
+	if err := h.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.(Editor).Set("ns", "namespace"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "name: worker\nimage: ${REGISTRY}/api # pushed by CI\nnamespace: ns\n"
+	if got := readFile(t, filepath.Join(dir, "inkube.yaml")); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
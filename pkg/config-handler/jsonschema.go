@@ -0,0 +1,79 @@
+package confighandler
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// JSONSchema generates a JSON Schema for T from its yaml, jsonschema and
+// description struct tags. Editors use it to complete and check config files.
+func JSONSchema[T any]() map[string]any {
+	s := typeSchema(reflect.TypeFor[T]())
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := structFields(t)
+		props := map[string]any{}
+		required := []string{}
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			f := fields[name]
+			p := typeSchema(f.typ)
+			if f.description != "" {
+				p["description"] = f.description
+			}
+			if len(f.enum) > 0 {
+				p["enum"] = f.enum
+			}
+			if f.required {
+				required = append(required, name)
+			}
+			props[name] = p
+		}
+
+		s := map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	}
+
+	return map[string]any{}
+}
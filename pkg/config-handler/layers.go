@@ -0,0 +1,319 @@
+package confighandler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	fn "github.com/abdheshnayak/inkube/pkg/fn"
+	"gopkg.in/yaml.v3"
+)
+
+type options struct {
+	localPath  string
+	extendsKey string
+	verify     func(path string, b []byte) error
+	expand     func(s string) (string, error)
+}
+
+type LayerOption func(*options)
+
+// WithLocal adds an optional overlay on top of the main file, it is merged
+// last and wins over every other layer.
+func WithLocal(path string) LayerOption {
+	return func(o *options) {
+		o.localPath = path
+	}
+}
+
+// WithExtends makes the main file (and its bases) able to point to a base
+// file through key. Base paths are relative to the file that references them.
+func WithExtends(key string) LayerOption {
+	return func(o *options) {
+		o.extendsKey = key
+	}
+}
+
+// WithVerify runs check against the content of every layer before it is
+// merged, an error aborts the read.
+func WithVerify(check func(path string, b []byte) error) LayerOption {
+	return func(o *options) {
+		o.verify = check
+	}
+}
+
+// WithExpand runs every string value of the merged config through expand
+// before it is decoded, e.g. to interpolate variables. Files keep the raw
+// values: only keys changed after Read are written back.
+func WithExpand(expand func(s string) (string, error)) LayerOption {
+	return func(o *options) {
+		o.expand = expand
+	}
+}
+
+type layer struct {
+	path     string
+	doc      *yaml.Node
+	writable bool
+	dirty    bool
+}
+
+type layered[T any] struct {
+	data *T
+	path string
+	opts options
+
+	// layers are ordered from the lowest to the highest priority
+	layers []*layer
+
+	// snapshot is data as it was after the last Read or Write, it is used
+	// to find out which keys were changed
+	snapshot *yaml.Node
+}
+
+// GetLayeredHandler returns a handler that merges a chain of files into T.
+// The chain is: extended bases, path itself, and the local overlay. Write
+// only touches the layers owning the keys that changed since the last Read.
+func GetLayeredHandler[T any](path string, opts ...LayerOption) Config[T] {
+	l := &layered[T]{
+		data: new(T),
+		path: path,
+	}
+
+	for _, o := range opts {
+		o(&l.opts)
+	}
+
+	return l
+}
+
+func (c *layered[T]) readDoc(path string) (*yaml.Node, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.opts.verify != nil {
+		if err := c.opts.verify(path, b); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := parseNode(b)
+	if err != nil {
+		return nil, fn.NewE(err, fmt.Sprintf("failed to parse %s", path))
+	}
+
+	return doc, nil
+}
+
+func (c *layered[T]) readLayers() ([]*layer, error) {
+	main, err := c.readDoc(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []*layer{{path: c.path, doc: main, writable: true}}
+
+	if c.opts.extendsKey != "" {
+		seen := map[string]bool{c.path: true}
+		curr := layers[0]
+		for {
+			v, ok := lookup(curr.doc, []string{c.opts.extendsKey})
+			if !ok || v.Kind == yaml.ScalarNode && (v.ShortTag() == "!!null" || v.Value == "") {
+				break
+			}
+
+			if v.Kind != yaml.ScalarNode {
+				return nil, fn.Errorf("%s: %s must be a path", curr.path, c.opts.extendsKey)
+			}
+
+			base := v.Value
+			if !filepath.IsAbs(base) {
+				base = filepath.Join(filepath.Dir(curr.path), base)
+			}
+
+			if seen[base] {
+				return nil, fn.Errorf("%s: circular %s of %s", curr.path, c.opts.extendsKey, base)
+			}
+			seen[base] = true
+
+			doc, err := c.readDoc(base)
+			if err != nil {
+				return nil, fn.NewE(err, fmt.Sprintf("failed to read %s", base))
+			}
+
+			curr = &layer{path: base, doc: doc}
+			layers = append([]*layer{curr}, layers...)
+		}
+	}
+
+	if c.opts.localPath != "" {
+		doc, err := c.readDoc(c.opts.localPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if doc == nil {
+			doc = &yaml.Node{Kind: yaml.DocumentNode}
+		}
+
+		// the local overlay is created on demand, only when a key it owns changes
+		layers = append(layers, &layer{path: c.opts.localPath, doc: doc, writable: true})
+	}
+
+	return layers, nil
+}
+
+func (c *layered[T]) Read() (*T, error) {
+	layers, err := c.readLayers()
+	if err != nil {
+		return c.data, err
+	}
+
+	v, err := c.decode(layers)
+	if err != nil {
+		return c.data, err
+	}
+
+	snapshot, err := toNode(v)
+	if err != nil {
+		return c.data, err
+	}
+
+	c.layers = layers
+	c.data = v
+	c.snapshot = snapshot
+	return v, nil
+}
+
+// decode merges layers into a new T.
+func (c *layered[T]) decode(layers []*layer) (*T, error) {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, l := range layers {
+		merged = mergeNodes(merged, rootOf(l.doc))
+	}
+
+	if c.opts.expand != nil {
+		// layers share nodes with merged, they must stay as they were read
+		merged = copyNode(merged)
+		if err := expandNode(merged, nil, c.opts.expand); err != nil {
+			return nil, err
+		}
+	}
+
+	v := new(T)
+	if err := merged.Decode(v); err != nil {
+		return nil, fn.NewE(err)
+	}
+
+	return v, nil
+}
+
+// Write saves the keys changed since the last Read, nothing is written when
+// data wasn't touched. Layers are read again under the lock, so that changes
+// made by another process in the meantime are kept.
+func (c *layered[T]) Write() error {
+	if c.layers == nil {
+		return WriteConfig(c.path, c.data, 0o644)
+	}
+
+	curr, err := toNode(c.data)
+	if err != nil {
+		return err
+	}
+
+	changes := diffNodes(c.snapshot, curr, nil)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := withLock(c.path, func() error {
+		layers, err := c.readLayers()
+		if err != nil {
+			return err
+		}
+		c.layers = layers
+
+		for _, ch := range changes {
+			if ch.removed {
+				for _, l := range c.layers {
+					if l.writable && unsetIn(l.doc, ch.path) {
+						l.dirty = true
+					}
+				}
+				continue
+			}
+
+			l := c.owner(ch.path)
+			setIn(l.doc, ch.path, ch.value)
+			l.dirty = true
+		}
+
+		return c.writeLayers()
+	}); err != nil {
+		return err
+	}
+
+	c.snapshot = curr
+	return nil
+}
+
+// writeLayers writes the dirty layers, once they are known to still form a
+// valid config.
+func (c *layered[T]) writeLayers() error {
+	if _, err := c.decode(c.layers); err != nil {
+		return err
+	}
+
+	for _, l := range c.layers {
+		if !l.dirty {
+			continue
+		}
+
+		b, err := encodeNode(l.doc)
+		if err != nil {
+			return err
+		}
+
+		// a change must not leave behind a file that can't be read back
+		if c.opts.verify != nil {
+			if err := c.opts.verify(l.path, b); err != nil {
+				return err
+			}
+		}
+
+		if err := writeFile(l.path, b, 0o644); err != nil {
+			return err
+		}
+
+		l.dirty = false
+	}
+
+	return nil
+}
+
+// owner returns the writable layer that should receive a change to path: the
+// highest priority layer defining the longest prefix of it, or the main file.
+func (c *layered[T]) owner(path []string) *layer {
+	for n := len(path); n > 0; n-- {
+		for i := len(c.layers) - 1; i >= 0; i-- {
+			l := c.layers[i]
+			if !l.writable {
+				continue
+			}
+
+			if _, ok := lookup(l.doc, path[:n]); ok {
+				return l
+			}
+		}
+	}
+
+	for _, l := range c.layers {
+		if l.path == c.path {
+			return l
+		}
+	}
+
+	return c.layers[0]
+}
@@ -0,0 +1,240 @@
+package confighandler
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testConfig struct {
+	Extends   string            `yaml:"extends,omitempty"`
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Image     string            `yaml:"image,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, s := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func layeredFiles(t *testing.T) string {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"root.yaml": "name: root\nimage: root\n",
+		"base.yaml": "extends: root.yaml\nnamespace: base\nenv:\n  A: base\n  B: base\n",
+		"inkube.yaml": "extends: base.yaml\n" +
+			"# the app\n" +
+			"name: main # not the base one\n" +
+			"env:\n  B: main\n",
+		"inkube.local.yaml": "namespace: local\nenv:\n  C: local\n",
+	})
+	return dir
+}
+
+func newLayered(dir string) Config[testConfig] {
+	return GetLayeredHandler[testConfig](filepath.Join(dir, "inkube.yaml"),
+		WithExtends("extends"),
+		WithLocal(filepath.Join(dir, "inkube.local.yaml")),
+	)
+}
+
+func TestLayersMerge(t *testing.T) {
+	dir := layeredFiles(t)
+
+	cfg, err := newLayered(dir).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// root < base < inkube.yaml < inkube.local.yaml, maps are merged
+	if cfg.Name != "main" || cfg.Namespace != "local" || cfg.Image != "root" {
+		t.Errorf("got name %q, namespace %q, image %q, want main, local, root", cfg.Name, cfg.Namespace, cfg.Image)
+	}
+
+	if want := map[string]string{"A": "base", "B": "main", "C": "local"}; !maps.Equal(cfg.Env, want) {
+		t.Errorf("got env %v, want %v", cfg.Env, want)
+	}
+}
+
+func TestLayersExtendsErrors(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"circular": {
+			"inkube.yaml": "extends: base.yaml\n",
+			"base.yaml":   "extends: inkube.yaml\n",
+		},
+		"missing base": {
+			"inkube.yaml": "extends: base.yaml\n",
+		},
+		"not a path": {
+			"inkube.yaml": "extends:\n  - base.yaml\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, files)
+
+			if _, err := newLayered(dir).Read(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestLayersWrite(t *testing.T) {
+	dir := layeredFiles(t)
+	files := []string{"root.yaml", "base.yaml"}
+	before := map[string]string{}
+	for _, f := range files {
+		before[f] = readFile(t, filepath.Join(dir, f))
+	}
+
+	h := newLayered(dir)
+	cfg, err := h.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Name = "renamed"  // owned by inkube.yaml
+	cfg.Namespace = "dev" // owned by inkube.local.yaml
+	cfg.Image = "app"     // only in a base, the main file takes it
+	cfg.Env["A"] = "set"  // env is defined by the local overlay last
+	delete(cfg.Env, "B")
+
+	if err := h.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	// bases are never written
+	for _, f := range files {
+		if got := readFile(t, filepath.Join(dir, f)); got != before[f] {
+			t.Errorf("%s changed:\n%s", f, got)
+		}
+	}
+
+	main := readFile(t, filepath.Join(dir, "inkube.yaml"))
+	for _, s := range []string{"# the app\nname: renamed # not the base one\n", "image: app\n"} {
+		if !strings.Contains(main, s) {
+			t.Errorf("inkube.yaml is missing %q:\n%s", s, main)
+		}
+	}
+	if strings.Contains(main, "B:") || strings.Contains(main, "namespace") {
+		t.Errorf("inkube.yaml holds keys it doesn't own:\n%s", main)
+	}
+
+	local := readFile(t, filepath.Join(dir, "inkube.local.yaml"))
+	if want := "namespace: dev\nenv:\n  C: local\n  A: set\n"; local != want {
+		t.Errorf("got inkube.local.yaml\n%s\nwant\n%s", local, want)
+	}
+
+	// B is only removed from the writable layers, the base one shows again
+	cfg, err = newLayered(dir).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"A": "set", "B": "base", "C": "local"}; !maps.Equal(cfg.Env, want) {
+		t.Errorf("got env %v, want %v", cfg.Env, want)
+	}
+}
+
+func TestLayersWriteUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"inkube.yaml": "name: main\nnamespace: ns\n"})
+
+	h := newLayered(dir)
+	if _, err := h.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the local overlay is only created for a key it owns
+	if _, err := os.Stat(filepath.Join(dir, "inkube.local.yaml")); !os.IsNotExist(err) {
+		t.Errorf("inkube.local.yaml was created")
+	}
+}
+
+func TestLayersSet(t *testing.T) {
+	dir := layeredFiles(t)
+
+	h := newLayered(dir)
+	if _, err := h.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	e := h.(Editor)
+	if err := e.Set("set", "env", "D"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("renamed", "name"); err != nil {
+		t.Fatal(err)
+	}
+
+	if local := readFile(t, filepath.Join(dir, "inkube.local.yaml")); !strings.Contains(local, "  D: set\n") {
+		t.Errorf("env.D wasn't written to inkube.local.yaml:\n%s", local)
+	}
+	if main := readFile(t, filepath.Join(dir, "inkube.yaml")); !strings.Contains(main, "name: renamed # not the base one\n") {
+		t.Errorf("name wasn't written to inkube.yaml:\n%s", main)
+	}
+
+	removed, err := e.Unset("namespace")
+	if err != nil || !removed {
+		t.Fatalf("got %v, %v", removed, err)
+	}
+
+	cfg, err := h.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Namespace != "base" || cfg.Env["D"] != "set" || cfg.Name != "renamed" {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestLayersVerify(t *testing.T) {
+	dir := layeredFiles(t)
+
+	h := GetLayeredHandler[testConfig](filepath.Join(dir, "inkube.yaml"),
+		WithExtends("extends"),
+		WithLocal(filepath.Join(dir, "inkube.local.yaml")),
+		WithVerify(func(path string, b []byte) error {
+			return Validate[testConfig](path, b, true)
+		}),
+	)
+
+	if _, err := h.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a value of the wrong type is refused before anything is written
+	before := readFile(t, filepath.Join(dir, "inkube.yaml"))
+	if err := h.(Editor).Set(map[string]string{"a": "b"}, "name"); err == nil {
+		t.Errorf("expected an error")
+	}
+	if got := readFile(t, filepath.Join(dir, "inkube.yaml")); got != before {
+		t.Errorf("inkube.yaml changed:\n%s", got)
+	}
+
+	writeFiles(t, dir, map[string]string{"base.yaml": "namespace: base\nunknown: true\n"})
+	if _, err := h.Read(); err == nil || !strings.Contains(err.Error(), "base.yaml:2:1: unknown: unknown field") {
+		t.Errorf("expected an unknown field error of base.yaml, got %v", err)
+	}
+}
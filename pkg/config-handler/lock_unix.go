@@ -0,0 +1,26 @@
+//go:build !windows
+
+package confighandler
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockDir takes an exclusive flock on dir, it blocks until the lock is free.
+func lockDir(dir string) (func(), error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
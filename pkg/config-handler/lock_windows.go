@@ -0,0 +1,8 @@
+//go:build windows
+
+package confighandler
+
+// lockDir is a no-op on windows, writes are still atomic.
+func lockDir(string) (func(), error) {
+	return func() {}, nil
+}
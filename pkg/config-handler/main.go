@@ -5,7 +5,7 @@ import (
 	"os"
 
 	fn "github.com/abdheshnayak/inkube/pkg/fn"
-	yaml "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 type Config[T any] interface {
@@ -54,73 +54,42 @@ func ReadConfig[T any](path string) (*T, error) {
 	return &v, nil
 }
 
+// WriteConfig merges v into the file at path, keys are merged recursively and
+// the comments and order of the existing ones are kept. The file is replaced
+// atomically while holding the lock of its directory.
 func WriteConfig(path string, v any, perm fs.FileMode) error {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		// If file doesn't exist, create it
-		if os.IsNotExist(err) {
-			b, err = yaml.Marshal(v)
-			if err != nil {
-				return fn.NewE(err)
-			}
-
-			return os.WriteFile(path, b, perm)
-		}
-
-		return fn.NewE(err)
-	}
-
-	var config yaml.MapSlice
-	if err := yaml.Unmarshal(b, &config); err != nil {
-		return fn.NewE(err)
-	}
-
-	if err := fillConfig(&config, v); err != nil {
-		return fn.NewE(err)
-	}
-
-	b, err = yaml.Marshal(config)
+	n, err := toNode(v)
 	if err != nil {
-		return fn.NewE(err)
+		return err
 	}
 
-	return os.WriteFile(path, b, perm)
-}
+	return withLock(path, func() error {
+		doc := &yaml.Node{Kind: yaml.DocumentNode}
 
-// FillConfig updates src with values from dest
-func fillConfig(src *yaml.MapSlice, dest any) error {
-	// Marshal dest to YAML
-	destBytes, err := yaml.Marshal(dest)
-	if err != nil {
-		return fn.NewE(err)
-	}
+		b, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fn.NewE(err)
+		}
 
-	// Unmarshal dest YAML into a MapSlice
-	var destMapSlice yaml.MapSlice
-	if err := yaml.Unmarshal(destBytes, &destMapSlice); err != nil {
-		return fn.NewE(err)
-	}
+		if err == nil {
+			if doc, err = parseNode(b); err != nil {
+				return err
+			}
+		}
 
-	// Iterate over destMapSlice and update src
-	for _, item := range destMapSlice {
-		key := item.Key
-		value := item.Value
-
-		// Check if key already exists in src, and update it if it does
-		found := false
-		for i, srcItem := range *src {
-			if srcItem.Key == key {
-				(*src)[i] = yaml.MapItem{Key: key, Value: value}
-				found = true
-				break
+		root := rootOf(doc)
+		for _, ch := range diffNodes(root, n, nil) {
+			// keys that are only in the file are left alone
+			if !ch.removed {
+				setIn(doc, ch.path, ch.value)
 			}
 		}
 
-		// If key doesn't exist in src, add it
-		if !found {
-			*src = append(*src, yaml.MapItem{Key: key, Value: value})
+		b, err = encodeNode(doc)
+		if err != nil {
+			return err
 		}
-	}
 
-	return nil
+		return writeFile(path, b, perm)
+	})
 }
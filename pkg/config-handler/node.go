@@ -0,0 +1,302 @@
+package confighandler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	fn "github.com/abdheshnayak/inkube/pkg/fn"
+	"gopkg.in/yaml.v3"
+)
+
+// Config files are edited as yaml nodes rather than decoded values, so
+// comments, key order and formatting of everything that isn't changed are
+// kept as they are.
+
+func parseNode(b []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fn.NewE(err)
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode}
+	}
+
+	return &doc, nil
+}
+
+func encodeNode(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fn.NewE(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, fn.NewE(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func toNode(v any) (*yaml.Node, error) {
+	if n, ok := v.(*yaml.Node); ok {
+		return n, nil
+	}
+
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return nil, fn.NewE(err)
+	}
+
+	return &n, nil
+}
+
+// rootOf returns the top level mapping of a document, creating it if the
+// document is empty.
+func rootOf(doc *yaml.Node) *yaml.Node {
+	if doc.Kind != yaml.DocumentNode {
+		return doc
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	return doc.Content[0]
+}
+
+func keyIndex(m *yaml.Node, key string) int {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return -1
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func lookup(doc *yaml.Node, path []string) (*yaml.Node, bool) {
+	curr := rootOf(doc)
+	for _, k := range path {
+		i := keyIndex(curr, k)
+		if i < 0 {
+			return nil, false
+		}
+		curr = curr.Content[i+1]
+	}
+
+	return curr, true
+}
+
+func setIn(doc *yaml.Node, path []string, value *yaml.Node) {
+	m := rootOf(doc)
+	for n, k := range path {
+		i := keyIndex(m, k)
+		if n == len(path)-1 {
+			if i < 0 {
+				m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, value)
+				return
+			}
+
+			old := m.Content[i+1]
+			if old.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && value.Style == 0 {
+				value.Style = old.Style
+			}
+			value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+			m.Content[i+1] = value
+			return
+		}
+
+		if i < 0 {
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+			i = len(m.Content) - 2
+		}
+
+		if m.Content[i+1].Kind != yaml.MappingNode {
+			m.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", LineComment: m.Content[i+1].LineComment}
+		}
+
+		m = m.Content[i+1]
+	}
+}
+
+// unsetIn removes path from doc, maps left empty by the removal are removed
+// as well.
+func unsetIn(doc *yaml.Node, path []string) bool {
+	return unsetKey(rootOf(doc), path)
+}
+
+func unsetKey(m *yaml.Node, path []string) bool {
+	i := keyIndex(m, path[0])
+	if i < 0 {
+		return false
+	}
+
+	if len(path) > 1 {
+		child := m.Content[i+1]
+		if !unsetKey(child, path[1:]) {
+			return false
+		}
+
+		if len(child.Content) > 0 {
+			return true
+		}
+	}
+
+	m.Content = append(m.Content[:i], m.Content[i+2:]...)
+	return true
+}
+
+// renameIn renames the last key of path in doc to name, its value and
+// comments stay where they are.
+func renameIn(doc *yaml.Node, path []string, name string) bool {
+	m, ok := lookup(doc, path[:len(path)-1])
+	if !ok {
+		return false
+	}
+
+	i := keyIndex(m, path[len(path)-1])
+	if i < 0 {
+		return false
+	}
+
+	m.Content[i].Value = name
+	return true
+}
+
+// mergeNodes merges the mapping src over dst, maps are merged recursively
+// and every other value is replaced. Neither argument is modified.
+func mergeNodes(dst, src *yaml.Node) *yaml.Node {
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	out.Content = append(out.Content, dst.Content...)
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		k, v := src.Content[i], src.Content[i+1]
+		j := keyIndex(out, k.Value)
+		if j < 0 {
+			out.Content = append(out.Content, k, v)
+			continue
+		}
+
+		if out.Content[j+1].Kind == yaml.MappingNode && v.Kind == yaml.MappingNode {
+			out.Content[j+1] = mergeNodes(out.Content[j+1], v)
+			continue
+		}
+
+		out.Content[j+1] = v
+	}
+
+	return out
+}
+
+func nodesEqual(a, b *yaml.Node) bool {
+	if a.Kind == yaml.AliasNode {
+		a = a.Alias
+	}
+	if b.Kind == yaml.AliasNode {
+		b = b.Alias
+	}
+
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+
+	if a.Kind == yaml.ScalarNode {
+		return a.ShortTag() == b.ShortTag() && a.Value == b.Value
+	}
+
+	for i := range a.Content {
+		if !nodesEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func copyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+
+	return &c
+}
+
+// expandNode replaces every string scalar of n by expand(value), in place.
+func expandNode(n *yaml.Node, path []string, expand func(string) (string, error)) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if err := expandNode(n.Content[i+1], append(path, n.Content[i].Value), expand); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			if err := expandNode(item, append(path, fmt.Sprint(i)), expand); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if n.ShortTag() != "!!str" {
+			return nil
+		}
+
+		v, err := expand(n.Value)
+		if err != nil {
+			return fn.Errorf("%s: %s", strings.Join(path, "."), err.Error())
+		}
+		n.Value = v
+	}
+
+	return nil
+}
+
+type change struct {
+	path    []string
+	value   *yaml.Node
+	removed bool
+}
+
+// diffNodes returns the leaves that differ between two mappings.
+func diffNodes(old, curr *yaml.Node, prefix []string) []change {
+	var changes []change
+	path := func(k string) []string {
+		return append(append([]string{}, prefix...), k)
+	}
+
+	for i := 0; i+1 < len(curr.Content); i += 2 {
+		k, v := curr.Content[i], curr.Content[i+1]
+		j := keyIndex(old, k.Value)
+		if j < 0 {
+			changes = append(changes, change{path: path(k.Value), value: v})
+			continue
+		}
+
+		o := old.Content[j+1]
+		if o.Kind == yaml.MappingNode && v.Kind == yaml.MappingNode {
+			changes = append(changes, diffNodes(o, v, path(k.Value))...)
+			continue
+		}
+
+		if !nodesEqual(o, v) {
+			changes = append(changes, change{path: path(k.Value), value: v})
+		}
+	}
+
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		if keyIndex(curr, old.Content[i].Value) < 0 {
+			changes = append(changes, change{path: path(old.Content[i].Value), removed: true})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,168 @@
+package confighandler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	fn "github.com/abdheshnayak/inkube/pkg/fn"
+	"gopkg.in/yaml.v3"
+)
+
+// Editor edits single keys of a config in place, in the layer that owns them.
+type Editor interface {
+	Set(value any, keys ...string) error
+	Unset(keys ...string) (bool, error)
+}
+
+// SplitPath splits a dotted path like loadEnv.overrides.FOO into its keys.
+func SplitPath(p string) []string {
+	return strings.Split(p, ".")
+}
+
+// FieldType returns the type found at keys inside T, following yaml field
+// names, map keys and list indexes.
+func FieldType[T any](keys ...string) (reflect.Type, error) {
+	t := reflect.TypeFor[T]()
+	for i, k := range keys {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := structFields(t)[k]
+			if !ok {
+				return nil, fn.Errorf("%s: unknown field", strings.Join(keys[:i+1], "."))
+			}
+			t = f.typ
+		case reflect.Map, reflect.Slice:
+			t = t.Elem()
+		default:
+			return nil, fn.Errorf("%s: is a %s, it has no field %s", strings.Join(keys[:i], "."), t.Kind(), k)
+		}
+	}
+
+	return t, nil
+}
+
+// ParseValue converts raw into a value that can be stored at a field of type
+// t. Strings are taken as they are, anything else is parsed as yaml and type
+// checked.
+func ParseValue(t reflect.Type, raw string, keys ...string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.String {
+		return raw, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &root); err != nil {
+		return nil, fn.NewE(err, "failed to parse value")
+	}
+
+	if len(root.Content) == 0 {
+		return nil, fn.Errorf("%s: value is empty", strings.Join(keys, "."))
+	}
+
+	v := &validator{file: "value", partial: true}
+	v.walk(root.Content[0], t, keys)
+	if len(v.issues) > 0 {
+		return nil, fn.Errorf("%s: %s", v.issues[0].Field, v.issues[0].Message)
+	}
+
+	// kept as a node, so it is written the way it was typed
+	return root.Content[0], nil
+}
+
+// Lookup returns the value found at keys inside v, as plain maps, lists and
+// scalars.
+func Lookup(v any, keys ...string) (any, bool) {
+	n, err := toNode(v)
+	if err != nil {
+		return nil, false
+	}
+
+	var curr any
+	if err := n.Decode(&curr); err != nil {
+		return nil, false
+	}
+
+	for _, k := range keys {
+		switch c := curr.(type) {
+		case map[string]any:
+			next, ok := c[k]
+			if !ok {
+				return nil, false
+			}
+			curr = next
+		case []any:
+			var i int
+			if _, err := fmt.Sscan(k, &i); err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			curr = c[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return curr, true
+}
+
+func (c *layered[T]) Set(value any, keys ...string) error {
+	n, err := toNode(value)
+	if err != nil {
+		return err
+	}
+
+	if err := withLock(c.path, func() error {
+		layers, err := c.readLayers()
+		if err != nil {
+			return err
+		}
+		c.layers = layers
+
+		l := c.owner(keys)
+		setIn(l.doc, keys, n)
+		l.dirty = true
+
+		return c.writeLayers()
+	}); err != nil {
+		return err
+	}
+
+	_, err = c.Read()
+	return err
+}
+
+func (c *layered[T]) Unset(keys ...string) (bool, error) {
+	removed := false
+	if err := withLock(c.path, func() error {
+		layers, err := c.readLayers()
+		if err != nil {
+			return err
+		}
+		c.layers = layers
+
+		for _, l := range c.layers {
+			if l.writable && unsetIn(l.doc, keys) {
+				l.dirty = true
+				removed = true
+			}
+		}
+
+		return c.writeLayers()
+	}); err != nil {
+		return removed, err
+	}
+
+	if !removed {
+		return false, nil
+	}
+
+	_, err := c.Read()
+	return removed, err
+}
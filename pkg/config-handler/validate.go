@@ -0,0 +1,234 @@
+package confighandler
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Issue is a single problem found in a config file.
+type Issue struct {
+	File    string
+	Line    int
+	Column  int
+	Field   string
+	Message string
+}
+
+func (i Issue) Error() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", i.File, i.Line, i.Column, i.Field, i.Message)
+}
+
+// ValidationError holds every issue found in a file.
+type ValidationError []Issue
+
+func (v ValidationError) Error() string {
+	lines := make([]string, 0, len(v))
+	for _, i := range v {
+		lines = append(lines, i.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate strictly checks the yaml in b against T: unknown fields, values of
+// the wrong type and, unless partial is set, missing required fields. Partial
+// documents are the ones merged with other layers.
+func Validate[T any](file string, b []byte, partial bool) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return ValidationError{{File: file, Line: 1, Column: 1, Message: err.Error()}}
+	}
+
+	// empty document
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	v := &validator{file: file, partial: partial}
+	v.walk(root.Content[0], reflect.TypeFor[T](), nil)
+
+	if len(v.issues) > 0 {
+		return v.issues
+	}
+	return nil
+}
+
+type validator struct {
+	file    string
+	partial bool
+	issues  ValidationError
+}
+
+func (v *validator) add(n *yaml.Node, path []string, format string, args ...any) {
+	v.issues = append(v.issues, Issue{
+		File:    v.file,
+		Line:    n.Line,
+		Column:  n.Column,
+		Field:   strings.Join(path, "."),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func kindName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a map"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", n.Value)
+	}
+}
+
+func (v *validator) walk(n *yaml.Node, t reflect.Type, path []string) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			v.add(n, path, "expected a map, got %s", kindName(n))
+			return
+		}
+
+		fields := structFields(t)
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, val := n.Content[i], n.Content[i+1]
+			f, ok := fields[k.Value]
+			if !ok {
+				v.add(k, append(path, k.Value), "unknown field")
+				continue
+			}
+
+			seen[k.Value] = true
+			v.walk(val, f.typ, append(path, k.Value))
+
+			if len(f.enum) > 0 && val.Kind == yaml.ScalarNode && !slices.Contains(f.enum, val.Value) {
+				v.add(val, append(path, k.Value), "expected one of %s, got %q", strings.Join(f.enum, ", "), val.Value)
+			}
+		}
+
+		if v.partial {
+			return
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			if fields[name].required && !seen[name] {
+				v.add(n, append(path, name), "missing required field")
+			}
+		}
+
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			v.add(n, path, "expected a map, got %s", kindName(n))
+			return
+		}
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, val := n.Content[i], n.Content[i+1]
+			v.walk(val, t.Elem(), append(path, k.Value))
+		}
+
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			v.add(n, path, "expected a list, got %s", kindName(n))
+			return
+		}
+
+		for i, item := range n.Content {
+			v.walk(item, t.Elem(), append(path, fmt.Sprint(i)))
+		}
+
+	case reflect.String:
+		if n.Kind != yaml.ScalarNode {
+			v.add(n, path, "expected a string, got %s", kindName(n))
+		}
+
+	case reflect.Bool:
+		if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!bool" {
+			v.add(n, path, "expected true or false, got %s", kindName(n))
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!int" {
+			v.add(n, path, "expected an integer, got %s", kindName(n))
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if n.Kind != yaml.ScalarNode || (n.ShortTag() != "!!float" && n.ShortTag() != "!!int") {
+			v.add(n, path, "expected a number, got %s", kindName(n))
+		}
+	}
+}
+
+type field struct {
+	typ         reflect.Type
+	required    bool
+	enum        []string
+	description string
+}
+
+// structFields returns the yaml fields of t by name, inline structs are
+// flattened into their parent.
+func structFields(t reflect.Type) map[string]field {
+	fields := map[string]field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag := sf.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			ft := sf.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			for k, f := range structFields(ft) {
+				fields[k] = f
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+
+		f := field{typ: sf.Type, description: sf.Tag.Get("description")}
+		for _, o := range strings.Split(sf.Tag.Get("jsonschema"), ",") {
+			switch {
+			case o == "required":
+				f.required = true
+			case strings.HasPrefix(o, "enum="):
+				f.enum = append(f.enum, strings.TrimPrefix(o, "enum="))
+			}
+		}
+
+		fields[name] = f
+	}
+
+	return fields
+}
@@ -0,0 +1,134 @@
+package confighandler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validateConfig struct {
+	Version string `yaml:"version" jsonschema:"required"`
+	Kind    string `yaml:"kind,omitempty" jsonschema:"enum=deployment,enum=pod"`
+	Port    int    `yaml:"port"`
+	Enabled bool   `yaml:"enabled"`
+
+	Inline `yaml:",inline"`
+
+	Env      map[string]string          `yaml:"env"`
+	Args     []string                   `yaml:"args"`
+	Profiles map[string]*validateConfig `yaml:"profiles"`
+}
+
+type Inline struct {
+	Namespace string `yaml:"namespace"`
+}
+
+func TestValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		yaml    string
+		partial bool
+		want    []string
+	}{
+		"valid": {
+			yaml: "version: v2\nkind: pod\nport: 8080\nenabled: true\nnamespace: ns\nenv:\n  A: b\nargs: [a, b]\n",
+		},
+		"empty": {
+			yaml: "",
+		},
+		"unknown fields": {
+			yaml: "version: v2\nnamspace: ns\nprofiles:\n  dev:\n    prot: 80\n",
+			want: []string{
+				"inkube.yaml:2:1: namspace: unknown field",
+				"inkube.yaml:5:5: profiles.dev.prot: unknown field",
+				"inkube.yaml:5:5: profiles.dev.version: missing required field",
+			},
+		},
+		"wrong types": {
+			yaml: "version: v2\nport: eighty\nenabled: yes please\nenv: [a]\nargs:\n  - a\n  - {b: c}\n",
+			want: []string{
+				`inkube.yaml:2:7: port: expected an integer, got "eighty"`,
+				`inkube.yaml:3:10: enabled: expected true or false, got "yes please"`,
+				"inkube.yaml:4:6: env: expected a map, got a list",
+				"inkube.yaml:7:5: args.1: expected a string, got a map",
+			},
+		},
+		"enum": {
+			yaml: "version: v2\nkind: job\n",
+			want: []string{`inkube.yaml:2:7: kind: expected one of deployment, pod, got "job"`},
+		},
+		"missing required": {
+			yaml: "namespace: ns\n",
+			want: []string{"inkube.yaml:1:1: version: missing required field"},
+		},
+		"partial": {
+			yaml:    "namespace: ns\nprofiles:\n  dev:\n    port: 80\n",
+			partial: true,
+		},
+		"partial still strict": {
+			yaml:    "namespace: [ns]\nextra: true\n",
+			partial: true,
+			want: []string{
+				"inkube.yaml:1:12: namespace: expected a string, got a list",
+				"inkube.yaml:2:1: extra: unknown field",
+			},
+		},
+		"syntax": {
+			yaml: "version: v2\n  port: 80\n",
+			want: []string{"inkube.yaml:1:1: yaml: line 2: mapping values are not allowed in this context"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := Validate[validateConfig]("inkube.yaml", []byte(tc.yaml), tc.partial)
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var verr ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a ValidationError", err)
+			}
+
+			if got := strings.Split(verr.Error(), "\n"); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	for _, tc := range []struct {
+		keys []string
+		raw  string
+		err  string
+	}{
+		// strings are taken as they are
+		{keys: []string{"namespace"}, raw: "[not, a, list]"},
+		{keys: []string{"port"}, raw: "8080"},
+		{keys: []string{"port"}, raw: "eighty", err: `port: expected an integer, got "eighty"`},
+		{keys: []string{"args"}, raw: "[a, b]"},
+		{keys: []string{"env"}, raw: "a", err: `env: expected a map, got "a"`},
+		{keys: []string{"profiles", "dev", "port"}, raw: "true", err: `profiles.dev.port: expected an integer, got "true"`},
+		{keys: []string{"enabled"}, raw: "", err: "enabled: value is empty"},
+	} {
+		typ, err := FieldType[validateConfig](tc.keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ParseValue(typ, tc.raw, tc.keys...)
+		if tc.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.raw, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.raw, err, tc.err)
+		}
+	}
+
+	if _, err := FieldType[validateConfig]("profiles", "dev", "unknown"); err == nil {
+		t.Errorf("expected an unknown field error")
+	}
+}
@@ -0,0 +1,34 @@
+package confighandler
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	fn "github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+// writeFile replaces path atomically, the mode of an existing file is kept.
+func writeFile(path string, b []byte, perm fs.FileMode) error {
+	if fi, err := os.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	if err := fn.WriteFileAtomic(path, b, perm); err != nil {
+		return fn.NewE(err, "failed to write "+path)
+	}
+
+	return nil
+}
+
+// withLock runs f while holding the advisory lock of the directory of path,
+// so that inkube processes don't edit the same config at once.
+func withLock(path string, f func() error) error {
+	unlock, err := lockDir(filepath.Dir(path))
+	if err != nil {
+		return fn.NewE(err, "failed to lock "+filepath.Dir(path))
+	}
+	defer unlock()
+
+	return f()
+}
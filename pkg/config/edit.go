@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+
+	cfhandler "github.com/abdheshnayak/inkube/pkg/config-handler"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+// Get returns the merged value at a dotted path like loadEnv.overrides.FOO.
+func (c *ConfigClient) Get(path string) (any, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return get(c.Config, path)
+}
+
+// Set type checks raw against the field at path and writes it to the config
+// layer that owns the key, leaving the rest of the file as it is.
+func (c *ConfigClient) Set(path string, raw string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := set(c.handler, path, raw); err != nil {
+		return err
+	}
+
+	return c.reload()
+}
+
+// Unset removes path from every config layer that defines it.
+func (c *ConfigClient) Unset(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := unset(c.handler, path, fmt.Sprintf("%s or %s", FileName, LocalFileName)); err != nil {
+		return err
+	}
+
+	return c.reload()
+}
+
+func get[T any](v *T, path string) (any, error) {
+	keys := cfhandler.SplitPath(path)
+	if _, err := cfhandler.FieldType[T](keys...); err != nil {
+		return nil, err
+	}
+
+	value, ok := cfhandler.Lookup(v, keys...)
+	if !ok {
+		return nil, fn.Errorf("%s is not set", path)
+	}
+
+	return value, nil
+}
+
+func set[T any](handler cfhandler.Config[T], path string, raw string) error {
+	keys := cfhandler.SplitPath(path)
+	t, err := cfhandler.FieldType[T](keys...)
+	if err != nil {
+		return err
+	}
+
+	v, err := cfhandler.ParseValue(t, raw, keys...)
+	if err != nil {
+		return err
+	}
+
+	e, ok := handler.(cfhandler.Editor)
+	if !ok {
+		return fn.Errorf("config can't be edited")
+	}
+
+	return e.Set(v, keys...)
+}
+
+// unset removes path from handler, files describes where it was looked for
+// when it isn't set anywhere.
+func unset[T any](handler cfhandler.Config[T], path string, files string) error {
+	keys := cfhandler.SplitPath(path)
+	if _, err := cfhandler.FieldType[T](keys...); err != nil {
+		return err
+	}
+
+	e, ok := handler.(cfhandler.Editor)
+	if !ok {
+		return fn.Errorf("config can't be edited")
+	}
+
+	removed, err := e.Unset(keys...)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return fn.Errorf("%s is not set in %s", path, files)
+	}
+
+	return nil
+}
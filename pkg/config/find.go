@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/abdheshnayak/inkube/flags"
+)
+
+// Find returns the path of the inkube.yaml to use. --config and INKUBE_CONFIG
+// win, a directory given there means the inkube.yaml inside it. Otherwise the
+// current directory and its parents are searched, like git does, and the
+// file in the current directory is returned when there is none.
+func Find() (string, error) {
+	if flags.ConfigPath != "" {
+		p, err := filepath.Abs(flags.ConfigPath)
+		if err != nil {
+			return "", err
+		}
+
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			return filepath.Join(p, FileName), nil
+		}
+
+		return p, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		p := filepath.Join(dir, FileName)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return filepath.Join(cwd, FileName), nil
+}
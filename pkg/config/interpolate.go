@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// interpolator expands ${...} references in config values: env vars and
+// the built-ins below. ${NAME:-default} falls back to default when NAME is
+// unset or empty, and $${ is a literal ${.
+type interpolator struct {
+	dir string
+
+	// cache of the built-ins, they shell out to git
+	values map[string]string
+}
+
+var builtins = map[string]func(dir string) (string, error){
+	"git.branch": func(dir string) (string, error) {
+		return git(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	},
+	"git.sha": func(dir string) (string, error) {
+		return git(dir, "rev-parse", "--short", "HEAD")
+	},
+	"user": func(string) (string, error) {
+		if u, err := user.Current(); err == nil {
+			return u.Username, nil
+		}
+
+		if u := os.Getenv("USER"); u != "" {
+			return u, nil
+		}
+
+		return "", fmt.Errorf("failed to find the current user")
+	},
+	"project.dir": func(dir string) (string, error) {
+		return dir, nil
+	},
+}
+
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed in %s: %w", strings.Join(args, " "), dir, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+func newInterpolator(dir string) *interpolator {
+	return &interpolator{dir: dir, values: map[string]string{}}
+}
+
+func (x *interpolator) lookup(name string) (string, error) {
+	if v, ok := x.values[name]; ok {
+		return v, nil
+	}
+
+	if b, ok := builtins[name]; ok {
+		v, err := b(x.dir)
+		if err != nil {
+			return "", err
+		}
+
+		x.values[name] = v
+		return v, nil
+	}
+
+	if strings.Contains(name, ".") {
+		return "", fmt.Errorf("unknown variable ${%s}", name)
+	}
+
+	return os.Getenv(name), nil
+}
+
+func (x *interpolator) expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		// $${ escapes the reference
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+
+		b.WriteString(s[:i])
+		ref := s[i+2 : i+end]
+		s = s[i+end+1:]
+
+		name, def, hasDef := strings.Cut(ref, ":-")
+		v, err := x.lookup(name)
+		if err != nil {
+			return "", err
+		}
+
+		if v == "" {
+			if !hasDef {
+				return "", fmt.Errorf("${%s} is not set", name)
+			}
+			v = def
+		}
+
+		b.WriteString(v)
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("REGISTRY", "ghcr.io")
+	t.Setenv("EMPTY", "")
+
+	x := newInterpolator("/src/app")
+
+	for in, want := range map[string]string{
+		"plain":                      "plain",
+		"${REGISTRY}/api":            "ghcr.io/api",
+		"$${REGISTRY}/api":           "${REGISTRY}/api",
+		"a$${b}c${REGISTRY}":         "a${b}cghcr.io",
+		"${UNSET_VAR:-docker.io}":    "docker.io",
+		"${EMPTY:-docker.io}":        "docker.io",
+		"${REGISTRY:-docker.io}":     "ghcr.io",
+		"${UNSET_VAR:-}x":            "x",
+		"${UNSET_VAR:-a:-b}":         "a:-b",
+		"${project.dir}/config.yaml": "/src/app/config.yaml",
+		"$REGISTRY":                  "$REGISTRY",
+	} {
+		got, err := x.expand(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	t.Setenv("EMPTY", "")
+
+	x := newInterpolator("/src/app")
+
+	for in, want := range map[string]string{
+		"${UNSET_VAR}/api":    "${UNSET_VAR} is not set",
+		"${EMPTY}":            "${EMPTY} is not set",
+		"${git.unknown}":      "unknown variable ${git.unknown}",
+		"${REGISTRY/api":      "unterminated ${",
+		"ok ${UNSET_VAR} end": "${UNSET_VAR} is not set",
+	} {
+		_, err := x.expand(in)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", in, err, want)
+		}
+	}
+}
@@ -10,6 +10,11 @@ import (
 	"github.com/abdheshnayak/inkube/pkg/fn"
 )
 
+const (
+	FileName      = "inkube.yaml"
+	LocalFileName = "inkube.local.yaml"
+)
+
 type ConfigClient struct {
 	*Config
 	handler cfhandler.Config[Config]
@@ -26,6 +31,11 @@ func (c *ConfigClient) Write() error {
 func (c *ConfigClient) Reload() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+
+	return c.reload()
+}
+
+func (c *ConfigClient) reload() error {
 	cfg, err := c.handler.Read()
 	if err != nil {
 		return err
@@ -34,15 +44,23 @@ func (c *ConfigClient) Reload() error {
 	return nil
 }
 
+// Dir returns the project directory, the one holding inkube.yaml.
+func (c *ConfigClient) Dir() string {
+	return path.Dir(c.path)
+}
+
 func NewConfig() (*ConfigClient, error) {
-	cwd, err := os.Getwd()
+	cpath, err := Find()
 	if err != nil {
 		return nil, err
 	}
 
-	cpath := path.Join(cwd, "inkube.yaml")
-
-	c := cfhandler.GetHandler[Config](cpath)
+	c := cfhandler.GetLayeredHandler[Config](cpath,
+		cfhandler.WithExtends("extends"),
+		cfhandler.WithLocal(path.Join(path.Dir(cpath), LocalFileName)),
+		cfhandler.WithVerify(verify(cpath)),
+		cfhandler.WithExpand(newInterpolator(path.Dir(cpath)).expand),
+	)
 	resp := &ConfigClient{
 		handler: c,
 		Config:  &Config{},
@@ -80,7 +98,6 @@ func Singleton() *ConfigClient {
 			fn.PrintError(err)
 			os.Exit(1)
 		}
-		config.Write()
 	})
 	return config
 }
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+// DefaultProfile is the name used for the top-level target of inkube.yaml.
+const DefaultProfile = "default"
+
+// Profile returns the name of the profile the current command acts on.
+// `--profile` and INKUBE_PROFILE take precedence over the `active` pointer
+// stored in the file.
+func (c *ConfigClient) Profile() string {
+	if flags.Profile != "" {
+		return flags.Profile
+	}
+
+	if c.Active != "" {
+		return c.Active
+	}
+
+	return DefaultProfile
+}
+
+// Target returns the resolved target for the current command. The returned
+// pointer is backed by the config, so changes to it are persisted by Write.
+func (c *ConfigClient) Target() (*Target, error) {
+	return c.GetProfile(c.Profile())
+}
+
+func (c *ConfigClient) GetProfile(name string) (*Target, error) {
+	if name == "" || name == DefaultProfile {
+		return &c.Config.Target, nil
+	}
+
+	t, ok := c.Profiles[name]
+	if !ok || t == nil {
+		return nil, fn.Errorf("profile %q not found, please run `inkube switch --profile %s` to create it", name, name)
+	}
+
+	return t, nil
+}
+
+// SetProfile stores t under name, creating the profile if needed.
+func (c *ConfigClient) SetProfile(name string, t Target) {
+	if name == "" || name == DefaultProfile {
+		c.Config.Target = t
+		return
+	}
+
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Target{}
+	}
+
+	c.Profiles[name] = &t
+}
+
+// pinKube makes the kube client and the connect backends use the context and
+// kubeconfig of t.
+func (c *ConfigClient) pinKube(t *Target) {
+	if t.Context != "" {
+		flags.KubeContext = t.Context
+	}
+
+	if t.Kubeconfig != "" {
+		p := os.ExpandEnv(t.Kubeconfig)
+		if strings.HasPrefix(p, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				p = path.Join(home, p[2:])
+			}
+		}
+
+		if !path.IsAbs(p) {
+			p = path.Join(c.Dir(), p)
+		}
+
+		flags.Kubeconfig = p
+	}
+}
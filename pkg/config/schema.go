@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	cfhandler "github.com/abdheshnayak/inkube/pkg/config-handler"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// schema describes one version of inkube.yaml.
+type schema struct {
+	version string
+
+	// validate strictly checks a file written with this version against the
+	// Go struct describing it. Files of older versions are refused until they
+	// are migrated, and checked before that.
+	validate func(file string, b []byte, partial bool) error
+
+	// migrate rewrites a document of this version into the next one in
+	// schemas, it is nil for the current version. Documents may be partial
+	// (extended bases and local overlays), so migrations must only touch the
+	// keys they are about.
+	migrate func(doc *cfhandler.Document) error
+}
+
+// schemas lists every known version of inkube.yaml, oldest first. The last
+// entry is the version written by this build.
+var schemas = []schema{
+	{
+		version:  "v1",
+		validate: cfhandler.Validate[configV1],
+		migrate:  migrateContainers,
+	},
+	{
+		version:  "v2",
+		validate: cfhandler.Validate[Config],
+	},
+}
+
+func CurrentVersion() string {
+	return schemas[len(schemas)-1].version
+}
+
+func schemaIndex(version string) int {
+	return slices.IndexFunc(schemas, func(s schema) bool {
+		return s.version == version
+	})
+}
+
+// fileVersion returns the version declared by a config file. Files that don't
+// declare one (overlays usually don't) are treated as current.
+func fileVersion(b []byte) (string, error) {
+	var v struct {
+		Version string `yaml:"version"`
+	}
+
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return "", fn.NewE(err)
+	}
+
+	if v.Version == "" {
+		return CurrentVersion(), nil
+	}
+
+	return v.Version, nil
+}
+
+// verify checks every layer of the config before it is loaded: files written
+// with another schema version are refused, and the rest is strictly validated.
+// Every file but a main one that doesn't extend anything is partial.
+func verify(main string) func(path string, b []byte) error {
+	return func(path string, b []byte) error {
+		if err := checkVersion(path, b); err != nil {
+			return err
+		}
+
+		partial := path != main
+		if !partial {
+			doc, err := cfhandler.ParseDocument(b)
+			if err != nil {
+				return err
+			}
+			partial = doc.GetString("extends") != ""
+		}
+
+		return schemas[len(schemas)-1].validate(path, b, partial)
+	}
+}
+
+func checkVersion(path string, b []byte) error {
+	v, err := fileVersion(b)
+	if err != nil {
+		return fn.NewE(err, fmt.Sprintf("failed to parse %s", path))
+	}
+
+	switch i := schemaIndex(v); {
+	case i < 0:
+		return fn.Errorf("%s uses unknown config version %q, please upgrade inkube", path, v)
+	case v != CurrentVersion():
+		return fn.Errorf("%s uses config version %s, please run `inkube config migrate %s` to upgrade it to %s", path, v, path, CurrentVersion())
+	}
+
+	return nil
+}
+
+// Migrate upgrades the file at path to the current schema version, the
+// original file is kept next to it with a .<version>.bak suffix. It returns
+// the version the file was migrated from, which is the current version when
+// there was nothing to do.
+func Migrate(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	from, err := fileVersion(b)
+	if err != nil {
+		return "", err
+	}
+
+	i := schemaIndex(from)
+	if i < 0 {
+		return from, fn.Errorf("%s uses unknown config version %q, please upgrade inkube", path, from)
+	}
+
+	if from == CurrentVersion() {
+		return from, nil
+	}
+
+	// errors point at the file as it is, not at its migrated form
+	if err := schemas[i].validate(path, b, true); err != nil {
+		return from, fn.NewE(err, fmt.Sprintf("%s is not a valid %s config", path, from))
+	}
+
+	doc, err := cfhandler.ParseDocument(b)
+	if err != nil {
+		return from, err
+	}
+
+	for _, s := range schemas[i : len(schemas)-1] {
+		if err := s.migrate(doc); err != nil {
+			return from, fn.NewE(err, fmt.Sprintf("failed to migrate %s from %s", path, s.version))
+		}
+	}
+
+	if err := doc.Set(CurrentVersion(), "version"); err != nil {
+		return from, err
+	}
+
+	nb, err := doc.Bytes()
+	if err != nil {
+		return from, err
+	}
+
+	if err := schemas[len(schemas)-1].validate(path, nb, true); err != nil {
+		return from, fn.NewE(err, fmt.Sprintf("migrated %s is not valid", path))
+	}
+
+	if err := fn.CopyFile(path, fmt.Sprintf("%s.%s.bak", path, from)); err != nil {
+		return from, err
+	}
+
+	return from, doc.Write(path)
+}
+
+// migrateContainers turns loadEnv.container of the default target and of
+// every profile into the loadEnv.containers list of v2.
+func migrateContainers(doc *cfhandler.Document) error {
+	targets := [][]string{{"loadEnv"}}
+	if profiles, ok := doc.Get("profiles"); ok {
+		if m, ok := profiles.(map[string]any); ok {
+			for _, name := range slices.Sorted(maps.Keys(m)) {
+				targets = append(targets, []string{"profiles", name, "loadEnv"})
+			}
+		}
+	}
+
+	for _, t := range targets {
+		container := slices.Concat(t, []string{"container"})
+		containers := slices.Concat(t, []string{"containers"})
+
+		name := doc.GetString(container...)
+		if name == "" {
+			doc.Unset(container...)
+			continue
+		}
+
+		if !doc.Rename("containers", container...) {
+			continue
+		}
+
+		// written as [name] so that it stays on one line with its comment
+		list := &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq", Style: yamlv3.FlowStyle, Content: []*yamlv3.Node{
+			{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: name},
+		}}
+		if err := doc.Set(list, containers...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// JSONSchema returns the JSON Schema of the current config version.
+func JSONSchema() map[string]any {
+	return cfhandler.JSONSchema[Config]()
+}
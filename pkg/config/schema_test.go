@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	check := verify("/src/inkube.yaml")
+
+	for _, tc := range []struct {
+		path string
+		yaml string
+		err  string
+	}{
+		{path: "/src/inkube.yaml", yaml: "version: v2\nnamespace: ns\nbridge:\n  name: api\n"},
+		{path: "/src/inkube.yaml", yaml: "namespace: ns\n", err: "/src/inkube.yaml:1:1: version: missing required field"},
+		{path: "/src/inkube.yaml", yaml: "version: v2\nbridge:\n  nmae: api\n", err: "/src/inkube.yaml:3:3: bridge.nmae: unknown field"},
+		{path: "/src/inkube.yaml", yaml: "version: v2\nloadEnv:\n  enabled: sometimes\n", err: `/src/inkube.yaml:3:12: loadEnv.enabled: expected true or false, got "sometimes"`},
+		{path: "/src/inkube.yaml", yaml: "version: v2\nprofiles:\n  dev:\n    bridge:\n      kind: service\n", err: `/src/inkube.yaml:5:13: profiles.dev.bridge.kind: expected one of deployment, statefulset, daemonset, replicaset, job, cronjob, pod, got "service"`},
+
+		// overlays and files extending a base are partial, but still strict
+		{path: "/src/inkube.local.yaml", yaml: "namespace: mine\n"},
+		{path: "/src/inkube.yaml", yaml: "extends: ../base.yaml\nnamespace: ns\n"},
+		{path: "/src/inkube.local.yaml", yaml: "namespace: mine\nnamepsace: typo\n", err: "/src/inkube.local.yaml:2:1: namepsace: unknown field"},
+
+		{path: "/src/inkube.yaml", yaml: "version: v1\n", err: "please run `inkube config migrate /src/inkube.yaml` to upgrade it to v2"},
+		{path: "/src/inkube.local.yaml", yaml: "version: v9\n", err: `unknown config version "v9"`},
+	} {
+		err := check(tc.path, []byte(tc.yaml))
+		if tc.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.yaml, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.yaml, err, tc.err)
+		}
+	}
+}
+
+func TestMigrateCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("version: v2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := Migrate(path)
+	if err != nil || from != CurrentVersion() {
+		t.Errorf("got %q, %v", from, err)
+	}
+
+	// nothing to do, no backup
+	if _, err := os.Stat(path + ".v2.bak"); !os.IsNotExist(err) {
+		t.Errorf("a backup was written")
+	}
+
+	if err := os.WriteFile(path, []byte("version: v0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(path); err == nil || !strings.Contains(err.Error(), "unknown config version") {
+		t.Errorf("expected an unknown version error, got %v", err)
+	}
+}
+
+func TestMigrateContainers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	v1 := `version: v1
+namespace: ns
+bridge:
+  name: api
+loadEnv:
+  container: api # the main one
+  enabled: true
+profiles:
+  worker:
+    bridge:
+      name: worker
+    loadEnv:
+      container: worker
+  init:
+    loadEnv:
+      container: init:migrate
+  none:
+    namespace: other
+    loadEnv:
+      container: ""
+`
+	if err := os.WriteFile(path, []byte(v1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := Migrate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != "v1" {
+		t.Errorf("migrated from %s, want v1", from)
+	}
+
+	want := `version: v2
+namespace: ns
+bridge:
+  name: api
+loadEnv:
+  containers: [api] # the main one
+  enabled: true
+profiles:
+  worker:
+    bridge:
+      name: worker
+    loadEnv:
+      containers: [worker]
+  init:
+    loadEnv:
+      containers: ['init:migrate']
+  none:
+    namespace: other
+`
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("got\n%s\nwant\n%s", b, want)
+	}
+
+	if b, err := os.ReadFile(path + ".v1.bak"); err != nil || string(b) != v1 {
+		t.Errorf("the backup doesn't hold the v1 file: %v", err)
+	}
+}
+
+func TestMigrateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	v1 := "version: v1\nloadEnv:\n  containers: [api]\n  enabled: sometimes\n"
+	if err := os.WriteFile(path, []byte(v1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Migrate(path)
+	for _, want := range []string{
+		"is not a valid v1 config",
+		path + ":3:3: loadEnv.containers: unknown field",
+		path + `:4:12: loadEnv.enabled: expected true or false, got "sometimes"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %v, want %q", err, want)
+		}
+	}
+
+	// left as it was
+	if b, err := os.ReadFile(path); err != nil || string(b) != v1 {
+		t.Errorf("the file was rewritten: %q %v", b, err)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); !os.IsNotExist(err) {
+		t.Errorf("a backup was written")
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+// configV1 is inkube.yaml as of v1, it only differs from Config in loadEnv.
+type configV1 struct {
+	Version string `yaml:"version" jsonschema:"required"`
+	Extends string `yaml:"extends,omitempty"`
+	Connect bool   `yaml:"connect"`
+
+	Target targetV1 `yaml:",inline"`
+
+	Devbox bool `yaml:"devbox"`
+
+	Settings Settings `yaml:"settings,omitempty"`
+
+	Active   string               `yaml:"active,omitempty"`
+	Profiles map[string]*targetV1 `yaml:"profiles,omitempty"`
+}
+
+type targetV1 struct {
+	Namespace  string `yaml:"namespace"`
+	Context    string `yaml:"context,omitempty"`
+	Kubeconfig string `yaml:"kubeconfig,omitempty"`
+
+	Bridge  BridgeConfig `yaml:"bridge"`
+	LoadEnv loadEnvV1    `yaml:"loadEnv"`
+}
+
+// loadEnvV1 names the single container env vars are read from, v2 turned it
+// into a list.
+type loadEnvV1 struct {
+	Name      *string `yaml:"name,omitempty"`
+	Kind      string  `yaml:"kind,omitempty" jsonschema:"enum=deployment,enum=statefulset,enum=daemonset,enum=replicaset,enum=job,enum=cronjob,enum=pod"`
+	Container string  `yaml:"container"`
+	Enabled   bool    `yaml:"enabled"`
+
+	Overrides     map[string]string `yaml:"overrides"`
+	OverridesFrom []string          `yaml:"overridesFrom,omitempty"`
+
+	Unset   []string `yaml:"unset,omitempty"`
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	Pod PodConfig `yaml:"pod,omitempty"`
+
+	Mounts MountsConfig `yaml:"mounts,omitempty"`
+
+	ServiceAccount ServiceAccountConfig `yaml:"serviceAccount,omitempty"`
+
+	Source string `yaml:"source,omitempty" jsonschema:"enum=spec,enum=exec"`
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/abdheshnayak/inkube/flags"
+	cfhandler "github.com/abdheshnayak/inkube/pkg/config-handler"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+const UserFileName = "config.yaml"
+
+// Settings are user preferences rather than a description of the project.
+// They are read from the user config, and a project can override them under
+// `settings` in inkube.yaml.
+type Settings struct {
+	Backend          string `yaml:"backend,omitempty" jsonschema:"enum=kubevpn,enum=telepresence" description:"tool used to connect to the cluster, defaults to kubevpn"`
+	ManagerNamespace string `yaml:"managerNamespace,omitempty" description:"namespace of the traffic manager, defaults to kubevpn for kubevpn and default for telepresence"`
+	Shell            string `yaml:"shell,omitempty" description:"shell started by inkube dev, defaults to $SHELL"`
+	Prompt           *bool  `yaml:"prompt,omitempty" description:"prefix the prompt of the dev shell with the inkube status"`
+	Notifications    *bool  `yaml:"notifications,omitempty" description:"show desktop notifications"`
+	Sound            *bool  `yaml:"sound,omitempty" description:"play a sound with alerts"`
+	Cache            *bool  `yaml:"cache,omitempty" description:"cache the env vars read from the cluster, encrypted. When false secrets are only kept in memory"`
+	CacheTTL         string `yaml:"cacheTTL,omitempty" description:"how long cached env vars are used when the cluster can't be reached to check them, e.g. 8h. Defaults to 24h"`
+}
+
+// apply copies the fields set in s over the runtime settings in flags.
+func (s Settings) apply() error {
+	if s.Backend != "" {
+		flags.Backend = s.Backend
+	}
+
+	if s.ManagerNamespace != "" {
+		flags.ManagerNamespace = s.ManagerNamespace
+	}
+
+	if s.Shell != "" {
+		flags.Shell = s.Shell
+	}
+
+	if s.Prompt != nil {
+		flags.Prompt = *s.Prompt
+	}
+
+	if s.Notifications != nil {
+		flags.Notifications = *s.Notifications
+	}
+
+	if s.Sound != nil {
+		flags.Sound = *s.Sound
+	}
+
+	if s.Cache != nil {
+		flags.Cache = *s.Cache
+	}
+
+	if s.CacheTTL != "" {
+		d, err := time.ParseDuration(s.CacheTTL)
+		if err != nil {
+			return fn.Errorf("invalid cacheTTL %q: %v", s.CacheTTL, err)
+		}
+		flags.CacheTTL = d
+	}
+
+	return nil
+}
+
+func envSettings() (Settings, error) {
+	s := Settings{
+		Backend:          os.Getenv("INKUBE_BACKEND"),
+		ManagerNamespace: os.Getenv("INKUBE_MANAGER_NAMESPACE"),
+		Shell:            os.Getenv("INKUBE_SHELL"),
+		CacheTTL:         os.Getenv("INKUBE_CACHE_TTL"),
+	}
+
+	for env, dst := range map[string]**bool{
+		"INKUBE_NO_PROMPT": &s.Prompt,
+		"INKUBE_NO_NOTIFY": &s.Notifications,
+		"INKUBE_NO_CACHE":  &s.Cache,
+	} {
+		v, ok := os.LookupEnv(env)
+		if !ok || v == "" {
+			continue
+		}
+
+		no, err := strconv.ParseBool(v)
+		if err != nil {
+			return s, fn.Errorf("%s must be true or false, got %q", env, v)
+		}
+
+		*dst = fn.Ptr(!no)
+	}
+
+	return s, nil
+}
+
+// Apply resolves the runtime state of this invocation into flags: the
+// settings, where later sources win (built-in defaults, the user config,
+// `settings` of the project config and env vars), and the kube context and
+// kubeconfig pinned by the target. Command line flags are applied by the
+// caller on top. A missing or broken project config is not an error here,
+// the commands that need it report it.
+func Apply() error {
+	user, err := NewUserConfig()
+	if err != nil {
+		return err
+	}
+	if err := user.apply(); err != nil {
+		return err
+	}
+
+	if cfg, err := NewConfig(); err == nil {
+		if err := cfg.Settings.apply(); err != nil {
+			return err
+		}
+
+		if t, err := cfg.Target(); err == nil {
+			cfg.pinKube(t)
+		}
+	}
+
+	env, err := envSettings()
+	if err != nil {
+		return err
+	}
+	return env.apply()
+}
+
+// UserConfig is the user config file, $XDG_CONFIG_HOME/inkube/config.yaml.
+type UserConfig struct {
+	*Settings
+	handler cfhandler.Config[Settings]
+	path    string
+	mu      sync.RWMutex
+}
+
+func UserConfigPath() string {
+	return path.Join(flags.ConfigDir, UserFileName)
+}
+
+func NewUserConfig() (*UserConfig, error) {
+	p := UserConfigPath()
+
+	c := &UserConfig{
+		Settings: &Settings{},
+		path:     p,
+		handler: cfhandler.GetLayeredHandler[Settings](p,
+			cfhandler.WithVerify(func(path string, b []byte) error {
+				return cfhandler.Validate[Settings](path, b, true)
+			}),
+		),
+	}
+
+	if _, err := os.Stat(p); os.IsNotExist(err) {
+		return c, nil
+	}
+
+	s, err := c.handler.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Settings = s
+	return c, nil
+}
+
+func (c *UserConfig) Path() string {
+	return c.path
+}
+
+// UserJSONSchema returns the JSON Schema of the user config.
+func UserJSONSchema() map[string]any {
+	return cfhandler.JSONSchema[Settings]()
+}
+
+func (c *UserConfig) Get(path string) (any, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return get(c.Settings, path)
+}
+
+func (c *UserConfig) Set(path string, raw string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the file is created by the first value set
+	if _, err := os.Stat(c.path); os.IsNotExist(err) {
+		if err := os.MkdirAll(flags.ConfigDir, 0o755); err != nil {
+			return fn.NewE(err)
+		}
+
+		if err := os.WriteFile(c.path, nil, 0o644); err != nil {
+			return fn.NewE(err)
+		}
+	}
+
+	if err := set(c.handler, path, raw); err != nil {
+		return err
+	}
+
+	s, err := c.handler.Read()
+	if err != nil {
+		return err
+	}
+
+	c.Settings = s
+	return nil
+}
+
+func (c *UserConfig) Unset(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := os.Stat(c.path); os.IsNotExist(err) {
+		return fn.Errorf("%s is not set in %s", path, c.path)
+	}
+
+	if err := unset(c.handler, path, c.path); err != nil {
+		return err
+	}
+
+	s, err := c.handler.Read()
+	if err != nil {
+		return err
+	}
+
+	c.Settings = s
+	return nil
+}
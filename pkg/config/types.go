@@ -1,28 +1,95 @@
 package config
 
+// PodConfig sets the pod Downward API values (fieldRef env vars) are read
+// from, or fakes them.
+type PodConfig struct {
+	Name   string            `yaml:"name,omitempty" description:"pod to read downward API values from, defaults to a running pod of the workload"`
+	Fields map[string]string `yaml:"fields,omitempty" description:"values used for field paths like metadata.name or status.podIP instead of the pod's"`
+}
+
+// MountsConfig mirrors the ConfigMap, Secret, projected and downwardAPI
+// volumes of the container to a local directory.
+type MountsConfig struct {
+	Enabled bool              `yaml:"enabled,omitempty" description:"mirror the volumes of the container to a temporary directory exported as INKUBE_MOUNT_ROOT, removed on exit"`
+	Rewrite map[string]string `yaml:"rewrite,omitempty" description:"local paths linked to paths of the container, e.g. /etc/config: ./config, relative to inkube.yaml. Env vars pointing under a container path are rewritten as well"`
+}
+
+// ServiceAccountConfig mints a token of the service account of the workload
+// for in-cluster clients.
+type ServiceAccountConfig struct {
+	Enabled    bool   `yaml:"enabled,omitempty" description:"write a token of the service account of the workload, the CA and the namespace under INKUBE_MOUNT_ROOT/var/run/secrets/kubernetes.io/serviceaccount, and set KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT"`
+	Expiration string `yaml:"expiration,omitempty" description:"lifetime of the token, e.g. 1h, it is refreshed before it expires. Defaults to 1h"`
+}
+
 type LoadEnv struct {
-	Name      *string `yaml:"name,omitempty"`
-	Container string  `yaml:"container"`
-	Enabled   bool    `yaml:"enabled"`
+	Name       *string  `yaml:"name,omitempty" description:"workload to read env vars from, defaults to bridge.name"`
+	Kind       string   `yaml:"kind,omitempty" jsonschema:"enum=deployment,enum=statefulset,enum=daemonset,enum=replicaset,enum=job,enum=cronjob,enum=pod" description:"kind of loadEnv.name, defaults to bridge.kind"`
+	Containers []string `yaml:"containers" description:"containers to read env vars from, merged in order so later containers win. Init containers, native sidecars included, are only read when named init:<name>"`
+	Enabled    bool     `yaml:"enabled" description:"load env vars of the containers into the dev shell"`
+
+	Overrides     map[string]string `yaml:"overrides" description:"env vars set on top of the ones read from the cluster"`
+	OverridesFrom []string          `yaml:"overridesFrom,omitempty" description:"dotenv files applied in order before overrides, relative to inkube.yaml. Missing files are skipped, so they can be git-ignored per developer"`
+
+	Unset   []string `yaml:"unset,omitempty" description:"env vars of the cluster left out, glob patterns like OTEL_* are allowed"`
+	Include []string `yaml:"include,omitempty" description:"regular expressions, only the env vars of the cluster with a matching name are kept"`
+	Exclude []string `yaml:"exclude,omitempty" description:"regular expressions, the env vars of the cluster with a matching name are left out"`
+
+	Pod PodConfig `yaml:"pod,omitempty" description:"pod downward API values are read from"`
+
+	Mounts MountsConfig `yaml:"mounts,omitempty" description:"volumes of the container mirrored to local files"`
 
-	Overrides map[string]string `yaml:"overrides"`
+	ServiceAccount ServiceAccountConfig `yaml:"serviceAccount,omitempty" description:"token of the service account of the workload for in-cluster clients"`
+
+	Source string `yaml:"source,omitempty" jsonschema:"enum=spec,enum=exec" description:"spec resolves the env of the pod template, exec also reads the env of a running pod, with the vars injected by webhooks. Defaults to spec"`
 }
 
 type BridgeConfig struct {
-	Name string `yaml:"name"`
+	Name string `yaml:"name" description:"name of the workload to connect to"`
+	Kind string `yaml:"kind,omitempty" jsonschema:"enum=deployment,enum=statefulset,enum=daemonset,enum=replicaset,enum=job,enum=cronjob,enum=pod" description:"kind of the workload, defaults to deployment"`
+
+	Intercept bool `yaml:"intercept" description:"intercept traffic of the workload"`
+}
+
+// Target is the part of the config that points inkube at a workload. The
+// top-level fields of inkube.yaml form the default target, and every entry
+// under `profiles` is a named one.
+type Target struct {
+	Namespace  string `yaml:"namespace" description:"namespace of the workload"`
+	Context    string `yaml:"context,omitempty" description:"kube context to use instead of the current one"`
+	Kubeconfig string `yaml:"kubeconfig,omitempty" description:"kubeconfig file to use, relative to inkube.yaml, defaults to $KUBECONFIG or ~/.kube/config"`
+
+	Bridge  BridgeConfig `yaml:"bridge"`
+	LoadEnv LoadEnv      `yaml:"loadEnv"`
+}
+
+// EnvWorkload returns the kind and name of the workload env vars are read
+// from, loadEnv falls back to bridge.
+func (t *Target) EnvWorkload() (kind, name string) {
+	kind, name = t.Bridge.Kind, t.Bridge.Name
+	if t.LoadEnv.Name != nil {
+		name = *t.LoadEnv.Name
+	}
+
+	if t.LoadEnv.Kind != "" {
+		kind = t.LoadEnv.Kind
+	}
 
-	Intercept bool `yaml:"intercept"`
+	return kind, name
 }
 
 type Config struct {
-	Version   string `yaml:"version"`
-	Namespace string `yaml:"namespace"`
-	Connect   bool   `yaml:"connect"`
+	Version string `yaml:"version" jsonschema:"required" description:"config schema version"`
+	Extends string `yaml:"extends,omitempty" description:"path of a base config merged below this file"`
+	Connect bool   `yaml:"connect" description:"connect to the cluster when starting a dev session"`
+
+	Target `yaml:",inline"`
+
+	Devbox bool `yaml:"devbox" description:"load devbox packages into the dev shell"`
 
-	Bridge BridgeConfig `yaml:"bridge"`
+	Settings Settings `yaml:"settings,omitempty" description:"user settings overridden for this project"`
 
-	Devbox  bool    `yaml:"devbox"`
-	LoadEnv LoadEnv `yaml:"loadEnv"`
+	Active   string             `yaml:"active,omitempty" description:"profile used when --profile is not given"`
+	Profiles map[string]*Target `yaml:"profiles,omitempty" description:"named targets"`
 }
 
 type ConfigLock struct {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"time"
 
 	"github.com/abdheshnayak/inkube/flags"
@@ -16,6 +17,10 @@ import (
 	"github.com/abdheshnayak/inkube/pkg/ui/text"
 )
 
+// KubeVpnStatusCache is the cache file `kubevpn status` is kept in for a few
+// seconds, the prompt reads it on every command.
+const KubeVpnStatusCache = "kubevpn.json"
+
 type KubeVpnClient struct {
 	managerNamespace string
 }
@@ -36,7 +41,7 @@ func (c *KubeVpnClient) Status() (connected, intercepted bool, err error) {
 	var b []byte
 	for {
 		s := flags.GetCacheDir()
-		cachePath := fmt.Sprintf("%s/kubevpn.json", s)
+		cachePath := fmt.Sprintf("%s/%s", s, KubeVpnStatusCache)
 		type KubeVpnStatus struct {
 			Data []byte
 			Time time.Time
@@ -169,7 +174,7 @@ func (c *KubeVpnClient) Connect(ns string) error {
 		return err
 	}
 
-	return fn.ExecCmd(fmt.Sprintf("kubevpn connect --manager-namespace=%s", c.managerNamespace), nil, false)
+	return fn.ExecCmd(fmt.Sprintf("kubevpn connect --manager-namespace=%s%s", c.managerNamespace, kubeFlags()), nil, false)
 }
 
 func (c *KubeVpnClient) Disconnect() error {
@@ -182,19 +187,32 @@ func (c *KubeVpnClient) Disconnect() error {
 	return fn.ExecCmd(fmt.Sprintf("kubevpn disconnect %d", i), nil, false)
 }
 
-func (c *KubeVpnClient) Intercept(name string, ns string) error {
+// kubevpnKinds are the kinds of workload kubevpn can proxy, a pod is proxied
+// through the controller owning it.
+var kubevpnKinds = []kube.WorkloadKind{kube.KindDeployment, kube.KindReplicaSet, kube.KindStatefulSet, kube.KindPod}
+
+func (c *KubeVpnClient) Intercept(workload kube.WorkloadRef, ns string) error {
+	if workload.Kind != "" && !slices.Contains(kubevpnKinds, workload.Kind) {
+		return fn.Errorf("kubevpn can't intercept a %s, only %v", workload.Kind, kubevpnKinds)
+	}
+
 	defer spinner.Client.UpdateMessage("intercepting pod")()
-	return fn.ExecCmd(fmt.Sprintf("kubevpn proxy deployment/%s -n %s --manager-namespace=%s", name, ns, c.managerNamespace), nil, false)
+	return fn.ExecCmd(fmt.Sprintf("kubevpn proxy %s -n %s --manager-namespace=%s%s", workload, ns, c.managerNamespace, kubeFlags()), nil, false)
 }
 
-func (c *KubeVpnClient) Leave(name string, ns string) error {
-	defer spinner.Client.UpdateMessage(fmt.Sprintf("leaving intercept for %s", name))()
+func (c *KubeVpnClient) Leave(workload kube.WorkloadRef, ns string) error {
+	defer spinner.Client.UpdateMessage(fmt.Sprintf("leaving intercept for %s", workload.Name))()
 
-	return fn.ExecCmd(fmt.Sprintf("kubevpn leave deployment/%s -n %s", name, ns), nil, false)
+	return fn.ExecCmd(fmt.Sprintf("kubevpn leave %s -n %s%s", workload, ns, kubeFlags()), nil, false)
 }
 
 func NewKubeVpn() ConnectClient {
+	ns := "kubevpn"
+	if flags.ManagerNamespace != "" {
+		ns = flags.ManagerNamespace
+	}
+
 	return &KubeVpnClient{
-		managerNamespace: "kubevpn",
+		managerNamespace: ns,
 	}
 }
@@ -1,21 +1,37 @@
 package connect
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+)
+
+const (
+	BackendKubeVpn      = "kubevpn"
+	BackendTelepresence = "telepresence"
+)
 
 type ConnectClient interface {
 	Status() (connected, intercept bool, err error)
 	IsConnected() (*string, int, error)
 	Connect(ns string) error
 	Disconnect() error
-	Intercept(name string, ns string) error
-	Leave(name string, ns string) error
+	Intercept(workload kube.WorkloadRef, ns string) error
+	Leave(workload kube.WorkloadRef, ns string) error
 
 	Quit() error
 
 	EnsureDependencies() error
 }
 
+// NewConnect returns the client of the backend chosen in the settings.
 func NewConnect() ConnectClient {
+	if flags.Backend == BackendTelepresence {
+		return NewTele()
+	}
+
 	return NewKubeVpn()
 }
 
@@ -26,8 +42,23 @@ var (
 
 func SClient() ConnectClient {
 	singleTon.Do(func() {
-		client = NewKubeVpn()
+		client = NewConnect()
 	})
 
 	return client
 }
+
+// kubeFlags returns the kubeconfig and context flags that pin kubevpn and
+// telepresence to the cluster of the project.
+func kubeFlags() string {
+	var s string
+	if flags.Kubeconfig != "" {
+		s += fmt.Sprintf(" --kubeconfig %q", flags.Kubeconfig)
+	}
+
+	if flags.KubeContext != "" {
+		s += fmt.Sprintf(" --context %q", flags.KubeContext)
+	}
+
+	return s
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"slices"
 
+	"github.com/abdheshnayak/inkube/flags"
 	"github.com/abdheshnayak/inkube/pkg/fn"
 	"github.com/abdheshnayak/inkube/pkg/kube"
 	"github.com/abdheshnayak/inkube/pkg/ui/spinner"
@@ -100,7 +102,7 @@ func (c *TeleClient) Connect(ns string) error {
 		return err
 	}
 
-	return fn.ExecCmd(fmt.Sprintf("telepresence connect -n %s", ns), nil, false)
+	return fn.ExecCmd(fmt.Sprintf("telepresence connect -n %s%s", ns, kubeFlags()), nil, false)
 }
 
 func (c *TeleClient) Disconnect() error {
@@ -109,20 +111,31 @@ func (c *TeleClient) Disconnect() error {
 	return fn.ExecCmd(fmt.Sprintf("telepresence quit"), nil, false)
 }
 
-func (c *TeleClient) Intercept(name string, ns string) error {
+// teleKinds are the kinds of workload telepresence can intercept.
+var teleKinds = []kube.WorkloadKind{kube.KindDeployment, kube.KindReplicaSet, kube.KindStatefulSet}
+
+func (c *TeleClient) Intercept(workload kube.WorkloadRef, ns string) error {
+	if workload.Kind != "" && !slices.Contains(teleKinds, workload.Kind) {
+		return fn.Errorf("telepresence can't intercept a %s, only %v", workload.Kind, teleKinds)
+	}
 
 	defer spinner.Client.UpdateMessage("intercepting pod")()
-	return fn.ExecCmd(fmt.Sprintf("telepresence intercept %s/%s", ns, name), nil, true)
+	return fn.ExecCmd(fmt.Sprintf("telepresence intercept %s/%s", ns, workload.Name), nil, true)
 }
 
-func (c *TeleClient) Leave(name string, ns string) error {
-	defer spinner.Client.UpdateMessage(fmt.Sprintf("leaving intercept for %s", name))()
+func (c *TeleClient) Leave(workload kube.WorkloadRef, ns string) error {
+	defer spinner.Client.UpdateMessage(fmt.Sprintf("leaving intercept for %s", workload.Name))()
 
-	return fn.ExecCmd(fmt.Sprintf("telepresence leave %s -n %s", name, ns), nil, true)
+	return fn.ExecCmd(fmt.Sprintf("telepresence leave %s -n %s", workload.Name, ns), nil, true)
 }
 
 func NewTele() ConnectClient {
+	ns := "default"
+	if flags.ManagerNamespace != "" {
+		ns = flags.ManagerNamespace
+	}
+
 	return &TeleClient{
-		managerNamespace: "default",
+		managerNamespace: ns,
 	}
 }
@@ -12,9 +12,9 @@ import (
 )
 
 type DevboxClient interface {
-	ShellEnv() (map[string]string, error)
+	ShellEnv(dir string) (map[string]string, error)
 	EnsureDependencies() error
-	EnsureInit() error
+	EnsureInit(dir string) error
 }
 
 type devboxClient struct {
@@ -28,22 +28,24 @@ func (d *devboxClient) EnsureDependencies() error {
 	return nil
 }
 
-func (d *devboxClient) EnsureInit() error {
-	if _, err := os.Stat("devbox.json"); err != nil && !os.IsNotExist(err) {
+// EnsureInit creates devbox.json in dir, the project directory, if it is
+// missing.
+func (d *devboxClient) EnsureInit(dir string) error {
+	if _, err := os.Stat(path.Join(dir, "devbox.json")); err != nil && !os.IsNotExist(err) {
 		return err
 	} else if os.IsNotExist(err) {
-		return fn.ExecCmd("devbox init", nil, false)
+		return fn.ExecCmd(fmt.Sprintf("devbox init %q", dir), nil, false)
 	}
 	return nil
 }
 
-func (d *devboxClient) ShellEnv() (map[string]string, error) {
-	if err := d.EnsureInit(); err != nil {
+func (d *devboxClient) ShellEnv(dir string) (map[string]string, error) {
+	if err := d.EnsureInit(dir); err != nil {
 		return nil, err
 	}
 
 	envs := make(map[string]string)
-	out, err := exec.Command("devbox", "shellenv", "--pure").Output()
+	out, err := exec.Command("devbox", "shellenv", "--pure", "--config", dir).Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get inkube shell env: %w", err)
 	}
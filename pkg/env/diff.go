@@ -0,0 +1,222 @@
+package env
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+)
+
+// The sides of a diff other than workload refs.
+const (
+	SideCache   = "cache"
+	SideCluster = "cluster"
+	SideDev     = "dev"
+)
+
+// Load reads the env of a side of a diff: the cache or the cluster, the dev
+// env of the target t, or a Ref. The cluster is read without touching the
+// cache, so that comparing it with the cache shows what is stale. The
+// include, exclude and unset of t apply to every side, like in Build.
+func Load(cfg *config.ConfigClient, t *config.Target, side string) (*Env, error) {
+	var envs *kube.Envs
+	var err error
+
+	switch side {
+	case SideCache:
+		envs, err = Cached(t)
+
+	case SideCluster:
+		envs, err = Cluster(t, kube.EnvOptions{Refetch: true, NoStore: true})
+
+	case SideDev:
+		return Build(cfg, t, Options{})
+
+	default:
+		r, perr := ParseRef(side, t)
+		if perr != nil {
+			return nil, perr
+		}
+		envs, err = Workload(r, t, false)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := filter(envs.Values, &t.LoadEnv); err != nil {
+		return nil, err
+	}
+
+	return FromCluster(envs), nil
+}
+
+// Change is a var set on both sides of a Diff, to different values.
+type Change struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff is what changes from one env to another.
+type Diff struct {
+	Added   map[string]string `json:"added"`
+	Removed map[string]string `json:"removed"`
+	Changed map[string]Change `json:"changed"`
+}
+
+// Compare returns the vars added, removed and changed from a to b.
+func Compare(a, b map[string]string) *Diff {
+	d := &Diff{Added: map[string]string{}, Removed: map[string]string{}, Changed: map[string]Change{}}
+
+	for k, v := range a {
+		to, ok := b[k]
+		switch {
+		case !ok:
+			d.Removed[k] = v
+		case to != v:
+			d.Changed[k] = Change{From: v, To: to}
+		}
+	}
+
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			d.Added[k] = v
+		}
+	}
+
+	return d
+}
+
+// Empty tells whether both envs are the same.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Keys returns the names of all the vars of d, sorted.
+func (d *Diff) Keys() []string {
+	keys := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for k := range d.Added {
+		keys = append(keys, k)
+	}
+	for k := range d.Removed {
+		keys = append(keys, k)
+	}
+	for k := range d.Changed {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Redact returns a copy of the diff from a to b with the values of secrets
+// replaced, and the number of vars redacted. A change is redacted when
+// either side is a secret.
+func (d *Diff) Redact(a, b *Env) (*Diff, int) {
+	r := &Diff{Added: map[string]string{}, Removed: map[string]string{}, Changed: map[string]Change{}}
+	n := 0
+
+	redact := func(e *Env, k, v string) string {
+		if v != "" && e.IsSecret(k) {
+			n++
+			return fn.Redacted
+		}
+		return v
+	}
+
+	for k, v := range d.Added {
+		r.Added[k] = redact(b, k, v)
+	}
+	for k, v := range d.Removed {
+		r.Removed[k] = redact(a, k, v)
+	}
+	for k, c := range d.Changed {
+		if a.IsSecret(k) || b.IsSecret(k) {
+			n++
+			c = Change{From: fn.Redacted, To: fn.Redacted}
+		}
+		r.Changed[k] = c
+	}
+
+	return r, n
+}
+
+// Ref points at containers of another workload than the target, like
+// staging/api:api,init:config.
+type Ref struct {
+	Namespace  string
+	Workload   kube.WorkloadRef
+	Containers []string
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%s/%s:%s", r.Namespace, r.Workload, strings.Join(r.Containers, ","))
+}
+
+// ParseRef parses [namespace/][kind/]name[:container,...], the parts left
+// out are the ones of the target t.
+func ParseRef(s string, t *config.Target) (Ref, error) {
+	kind, _ := t.EnvWorkload()
+	r := Ref{
+		Namespace:  t.Namespace,
+		Workload:   kube.WorkloadRef{Kind: kube.WorkloadKind(kind)},
+		Containers: t.LoadEnv.Containers,
+	}
+
+	s, containers, ok := strings.Cut(s, ":")
+	if ok {
+		r.Containers = strings.Split(containers, ",")
+	}
+
+	parts := strings.Split(s, "/")
+	switch len(parts) {
+	case 1:
+		r.Workload.Name = parts[0]
+	case 2:
+		// kind/name or namespace/name
+		if slices.Contains(kube.WorkloadKinds, kube.WorkloadKind(parts[0])) {
+			r.Workload.Kind = kube.WorkloadKind(parts[0])
+		} else {
+			r.Namespace = parts[0]
+		}
+		r.Workload.Name = parts[1]
+	case 3:
+		r.Namespace, r.Workload.Kind, r.Workload.Name = parts[0], kube.WorkloadKind(parts[1]), parts[2]
+	default:
+		return Ref{}, fn.Errorf("invalid workload %q, expected [namespace/][kind/]name[:container,...]", s)
+	}
+
+	if r.Workload.Kind != "" && !slices.Contains(kube.WorkloadKinds, r.Workload.Kind) {
+		return Ref{}, fn.Errorf("unknown workload kind %q in %q", r.Workload.Kind, s)
+	}
+
+	if slices.Contains(r.Containers, "") {
+		return Ref{}, fn.Errorf("invalid containers in %q", s)
+	}
+
+	container := ""
+	if len(r.Containers) > 0 {
+		container = r.Containers[0]
+	}
+
+	for name, v := range map[string]string{"name": r.Workload.Name, "namespace": r.Namespace, "container": container} {
+		if v == "" {
+			return Ref{}, fn.Errorf("the %s of %q is not set and the target has none", name, s)
+		}
+	}
+
+	return r, nil
+}
+
+// Workload reads the env of the containers of r from the cluster, the way
+// the env of the target t is read.
+func Workload(r Ref, t *config.Target, refetch bool) (*kube.Envs, error) {
+	return merge(r.Containers, func(container string) (*kube.Envs, error) {
+		return kubeClient().GetEnvs(r.Namespace, r.Workload, container, kube.EnvOptions{
+			Source:  kube.EnvSource(t.LoadEnv.Source),
+			Refetch: refetch,
+		})
+	})
+}
@@ -0,0 +1,204 @@
+package env
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestCompare(t *testing.T) {
+	a := map[string]string{"SAME": "1", "GONE": "x", "LOG_LEVEL": "info", "DB_PASSWORD": "local"}
+	b := map[string]string{"SAME": "1", "NEW": "y", "LOG_LEVEL": "debug", "DB_PASSWORD": "staging"}
+
+	d := Compare(a, b)
+
+	if !maps.Equal(d.Added, map[string]string{"NEW": "y"}) {
+		t.Errorf("added: got %v", d.Added)
+	}
+
+	if !maps.Equal(d.Removed, map[string]string{"GONE": "x"}) {
+		t.Errorf("removed: got %v", d.Removed)
+	}
+
+	want := map[string]Change{"LOG_LEVEL": {From: "info", To: "debug"}, "DB_PASSWORD": {From: "local", To: "staging"}}
+	if !maps.Equal(d.Changed, want) {
+		t.Errorf("changed: got %v, want %v", d.Changed, want)
+	}
+
+	// known from the provenance only
+	ea, eb := New(), New()
+	ea.Set(OriginCluster, a)
+	eb.Set(OriginCluster, b)
+	eb.Secrets["NEW"] = true
+
+	r, n := d.Redact(ea, eb)
+	if n != 2 || r.Changed["DB_PASSWORD"] != (Change{From: fn.Redacted, To: fn.Redacted}) || r.Added["NEW"] != fn.Redacted {
+		t.Errorf("got %v %v redacted %d, want the password and NEW redacted", r.Changed, r.Added, n)
+	}
+
+	if d.Changed["DB_PASSWORD"].To != "staging" {
+		t.Errorf("Redact changed the diff")
+	}
+
+	if !Compare(a, a).Empty() {
+		t.Errorf("an env differs from itself")
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	target := &config.Target{
+		Namespace: "dev",
+		Bridge:    config.BridgeConfig{Name: "api", Kind: "statefulset"},
+		LoadEnv:   config.LoadEnv{Containers: []string{"app"}},
+	}
+
+	tests := []struct {
+		ref  string
+		want Ref
+		err  bool
+	}{
+		{ref: "worker", want: Ref{Namespace: "dev", Workload: kube.WorkloadRef{Kind: kube.KindStatefulSet, Name: "worker"}, Containers: []string{"app"}}},
+		{ref: "staging/api:api", want: Ref{Namespace: "staging", Workload: kube.WorkloadRef{Kind: kube.KindStatefulSet, Name: "api"}, Containers: []string{"api"}}},
+		{ref: "deployment/api", want: Ref{Namespace: "dev", Workload: kube.WorkloadRef{Kind: kube.KindDeployment, Name: "api"}, Containers: []string{"app"}}},
+		{ref: "staging/pod/api-0:sidecar", want: Ref{Namespace: "staging", Workload: kube.WorkloadRef{Kind: kube.KindPod, Name: "api-0"}, Containers: []string{"sidecar"}}},
+		{ref: "api:app,init:config", want: Ref{Namespace: "dev", Workload: kube.WorkloadRef{Kind: kube.KindStatefulSet, Name: "api"}, Containers: []string{"app", "init:config"}}},
+		{ref: "staging/thing/api", err: true},
+		{ref: "a/b/c/d", err: true},
+		{ref: "staging/:api", err: true},
+		{ref: "api:", err: true},
+		{ref: "api:app,", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRef(tt.ref, target)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.ref)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", tt.ref, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+// fakeCluster serves a deployment dev/api whose container api has
+// LOG_LEVEL set to *level and two more vars, and reads the env from it.
+func fakeCluster(t *testing.T, level *string) {
+	flags.CacheDir = t.TempDir()
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "none"))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/apps/v1/namespaces/dev/deployments/api" {
+			http.NotFound(w, r)
+			return
+		}
+
+		d := appsv1.Deployment{
+			TypeMeta:   v1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: v1.ObjectMeta{Name: "api", Namespace: "dev", ResourceVersion: *level},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "api",
+				Env: []corev1.EnvVar{
+					{Name: "LOG_LEVEL", Value: *level},
+					{Name: "OTEL_ENDPOINT", Value: "http://otel:4317"},
+					{Name: "TRACE_SAMPLING", Value: "0.1"},
+				},
+			}}}}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := kube.NewClientForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := kubeClient
+	kubeClient = func() *kube.Client { return c }
+	t.Cleanup(func() { kubeClient = old })
+}
+
+func TestLoadStaleCache(t *testing.T) {
+	level := "info"
+	fakeCluster(t, &level)
+
+	target := &config.Target{
+		Namespace: "dev",
+		Bridge:    config.BridgeConfig{Name: "api"},
+		LoadEnv:   config.LoadEnv{Containers: []string{"api"}},
+	}
+
+	// cached, then changed in the cluster
+	if _, err := Cluster(target, kube.EnvOptions{Refetch: true}); err != nil {
+		t.Fatal(err)
+	}
+	level = "debug"
+
+	for _, sides := range [][2]string{{SideCache, SideCluster}, {SideCluster, SideCache}} {
+		a, err := Load(nil, target, sides[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Load(nil, target, sides[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{SideCache: "info", SideCluster: "debug"}
+		d := Compare(a.Vars, b.Vars)
+		if got := d.Changed["LOG_LEVEL"]; got != (Change{From: want[sides[0]], To: want[sides[1]]}) {
+			t.Errorf("%s %s: got %v, want LOG_LEVEL changed", sides[0], sides[1], d)
+		}
+	}
+}
+
+func TestLoadFilters(t *testing.T) {
+	level := "info"
+	fakeCluster(t, &level)
+
+	target := &config.Target{
+		Namespace: "dev",
+		Bridge:    config.BridgeConfig{Name: "api"},
+		LoadEnv: config.LoadEnv{
+			Containers: []string{"api"},
+			Exclude:    []string{"^OTEL_"},
+			Unset:      []string{"TRACE_*"},
+		},
+	}
+
+	if _, err := Cluster(target, kube.EnvOptions{Refetch: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, side := range []string{SideCache, SideCluster, "api"} {
+		e, err := Load(nil, target, side)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.Keys(), []string{"LOG_LEVEL"}) {
+			t.Errorf("%s: got %v, want only LOG_LEVEL", side, e.Keys())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package env
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var dotenvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ParseDotenv parses a dotenv file: KEY=value lines with an optional
+// `export `, # comments, 'literal' values and "quoted" values with \n, \t,
+// \\, \" and \$ escapes. Quoted values may span lines. ${VAR} references are
+// kept as is.
+func ParseDotenv(b []byte) (map[string]string, error) {
+	envs := map[string]string{}
+
+	s := strings.ReplaceAll(string(b), "\r\n", "\n")
+	line := 0
+	for s != "" {
+		var l string
+		l, s, _ = strings.Cut(s, "\n")
+		line++
+
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		l = strings.TrimPrefix(l, "export ")
+		k, v, ok := strings.Cut(l, "=")
+		k = strings.TrimSpace(k)
+		if !ok || !dotenvName.MatchString(k) {
+			return nil, fmt.Errorf("line %d: expected KEY=value", line)
+		}
+
+		v = strings.TrimLeft(v, " \t")
+		if v == "" || (v[0] != '"' && v[0] != '\'') {
+			// unquoted, up to a comment
+			if i := strings.Index(v, " #"); i >= 0 {
+				v = v[:i]
+			}
+			envs[k] = strings.TrimSpace(v)
+			continue
+		}
+
+		start := line
+		quote := v[0]
+		v = v[1:]
+
+		// the value runs up to the closing quote, over lines if need be
+		var sb strings.Builder
+		closed := false
+		for !closed {
+			for i := 0; i < len(v); i++ {
+				c := v[i]
+				if c == quote {
+					closed = true
+					if rest := strings.TrimSpace(v[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+						return nil, fmt.Errorf("line %d: unexpected %q after the value", line, rest)
+					}
+					break
+				}
+
+				if c == '\\' && quote == '"' && i+1 < len(v) {
+					i++
+					switch v[i] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 'r':
+						sb.WriteByte('\r')
+					case 't':
+						sb.WriteByte('\t')
+					case '\\', '"', '$', '`':
+						sb.WriteByte(v[i])
+					default:
+						sb.WriteByte('\\')
+						sb.WriteByte(v[i])
+					}
+					continue
+				}
+
+				sb.WriteByte(c)
+			}
+
+			if closed {
+				break
+			}
+
+			if s == "" {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", start)
+			}
+
+			sb.WriteByte('\n')
+			v, s, _ = strings.Cut(s, "\n")
+			line++
+		}
+
+		envs[k] = sb.String()
+	}
+
+	return envs, nil
+}
@@ -0,0 +1,105 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Format is an output format of Write.
+type Format string
+
+const (
+	FormatDotenv Format = "dotenv"
+	FormatShell  Format = "shell"
+	FormatFish   Format = "fish"
+	FormatJSON   Format = "json"
+	FormatYAML   Format = "yaml"
+	FormatDocker Format = "docker"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatDotenv, FormatShell, FormatFish, FormatJSON, FormatYAML, FormatDocker}
+
+// Format renders vars, sorted by name. It returns the names it had to
+// leave out, docker env-files can't hold multi-line values.
+func (f Format) Format(vars map[string]string) (out string, skipped []string, err error) {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	switch f {
+	case FormatJSON:
+		b, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			return "", nil, err
+		}
+		return string(b) + "\n", nil, nil
+
+	case FormatYAML:
+		b, err := yaml.Marshal(vars)
+		if err != nil {
+			return "", nil, err
+		}
+		return string(b), nil, nil
+	}
+
+	var sb strings.Builder
+	for _, k := range keys {
+		v := vars[k]
+		switch f {
+		case FormatDotenv:
+			fmt.Fprintf(&sb, "%s=%s\n", k, dotenvQuote(v))
+		case FormatShell:
+			fmt.Fprintf(&sb, "export %s=%s\n", k, shellQuote(v))
+		case FormatFish:
+			fmt.Fprintf(&sb, "set -gx %s %s\n", k, fishQuote(v))
+		case FormatDocker:
+			// read verbatim by docker, there is no quoting
+			if strings.ContainsAny(v, "\r\n") {
+				skipped = append(skipped, k)
+				continue
+			}
+			fmt.Fprintf(&sb, "%s=%s\n", k, v)
+		default:
+			return "", nil, fmt.Errorf("unknown format %q, expected one of %s", f, formatList())
+		}
+	}
+
+	return sb.String(), skipped, nil
+}
+
+func formatList() string {
+	s := make([]string, 0, len(Formats))
+	for _, f := range Formats {
+		s = append(s, string(f))
+	}
+	return strings.Join(s, ", ")
+}
+
+// dotenvQuote double quotes v when needed, with the escapes dotenv loaders
+// understand.
+func dotenvQuote(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\r\n\"'\\$#=`") {
+		return v
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `\$`, "`", "\\`")
+	return `"` + r.Replace(v) + `"`
+}
+
+// shellQuote single quotes v for POSIX shells.
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+// fishQuote single quotes v for fish, where \ and ' are escaped inside
+// single quotes.
+func fishQuote(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
@@ -0,0 +1,125 @@
+package env
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+func TestFormat(t *testing.T) {
+	vars := map[string]string{
+		"PLAIN": "value",
+		"QUOTE": `it's "quoted"`,
+		"MULTI": "a\nb",
+		"EMPTY": "",
+	}
+
+	tests := []struct {
+		format  Format
+		want    string
+		skipped []string
+	}{
+		{
+			format: FormatDotenv,
+			want:   "EMPTY=\"\"\nMULTI=\"a\\nb\"\nPLAIN=value\nQUOTE=\"it's \\\"quoted\\\"\"\n",
+		},
+		{
+			format: FormatShell,
+			want:   "export EMPTY=''\nexport MULTI='a\nb'\nexport PLAIN='value'\nexport QUOTE='it'\\''s \"quoted\"'\n",
+		},
+		{
+			format: FormatFish,
+			want:   "set -gx EMPTY ''\nset -gx MULTI 'a\nb'\nset -gx PLAIN 'value'\nset -gx QUOTE 'it\\'s \"quoted\"'\n",
+		},
+		{
+			format:  FormatDocker,
+			want:    "EMPTY=\nPLAIN=value\nQUOTE=it's \"quoted\"\n",
+			skipped: []string{"MULTI"},
+		},
+		{
+			format: FormatYAML,
+			want:   "EMPTY: \"\"\nMULTI: |-\n  a\n  b\nPLAIN: value\nQUOTE: it's \"quoted\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			got, skipped, err := tt.format.Format(vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+
+			if !slices.Equal(skipped, tt.skipped) {
+				t.Errorf("skipped %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+
+	if _, _, err := Format("xml").Format(vars); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	e := New()
+	e.Set(OriginCluster, map[string]string{
+		"DB_PASSWORD":     "hunter2",
+		"API_KEY":         "k",
+		"GITHUB_TOKEN":    "t",
+		"DATABASE_URL":    "postgres://app:hunter2@db:5432/app",
+		"REDIS_URL":       "redis://redis:6379",
+		"MONKEY":          "banana",
+		"EMPTY_SECRET":    "",
+		"TOKENIZER_MODEL": "bpe",
+		"FROM_SECRET":     "s3cr3t",
+	})
+	e.Secrets["FROM_SECRET"] = true
+
+	vars, n := e.Redact()
+
+	for k, redacted := range map[string]bool{
+		"DB_PASSWORD":     true,
+		"API_KEY":         true,
+		"GITHUB_TOKEN":    true,
+		"DATABASE_URL":    true,
+		"REDIS_URL":       false,
+		"MONKEY":          false,
+		"EMPTY_SECRET":    false,
+		"TOKENIZER_MODEL": false,
+		"FROM_SECRET":     true,
+	} {
+		if got := vars[k] == fn.Redacted; got != redacted {
+			t.Errorf("%s: redacted %v, want %v", k, got, redacted)
+		}
+	}
+
+	if n != 5 {
+		t.Errorf("got %d redacted, want 5", n)
+	}
+
+	if e.Vars["DB_PASSWORD"] != "hunter2" {
+		t.Errorf("Redact changed the env")
+	}
+}
+
+func TestOnly(t *testing.T) {
+	e := New()
+	e.Set(OriginCluster, map[string]string{"DB_HOST": "db", "DB_PORT": "5432", "PORT": "80", "HOST": "x"})
+
+	if err := e.Only([]string{"DB_*", "PORT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := e.Keys(), []string{"DB_HOST", "DB_PORT", "PORT"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := e.Only([]string{"["}); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
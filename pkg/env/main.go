@@ -0,0 +1,265 @@
+// Package env builds the env of a dev session, in this order:
+//
+//  1. the env of the containers read from the cluster, merged in order
+//  2. loadEnv.include and loadEnv.exclude, then loadEnv.unset, drop vars of
+//     the cluster
+//  3. the files of loadEnv.overridesFrom, in order, the values of encrypted
+//     files are secrets
+//  4. loadEnv.overrides
+//  5. the mirrored volumes and the service account token
+//  6. the devbox env
+//
+// Later steps win.
+package env
+
+import (
+	"maps"
+	"path"
+	"slices"
+
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/devbox"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+	"github.com/abdheshnayak/inkube/pkg/secrets"
+)
+
+// kubeClient is the client the env is read from the cluster with.
+var kubeClient = kube.Singleton
+
+// Origin tells which step of Build a value comes from.
+type Origin string
+
+const (
+	OriginCluster  Origin = "cluster"
+	OriginOverride Origin = "override"
+	OriginMount    Origin = "mount"
+	OriginDevbox   Origin = "devbox"
+)
+
+// Env is a set of env vars along with where each one comes from.
+type Env struct {
+	Vars    map[string]string
+	Origins map[string]Origin
+
+	// Secrets are the vars known to hold secrets, like the ones read from
+	// Secrets of the cluster.
+	Secrets map[string]bool
+}
+
+func New() *Env {
+	return &Env{Vars: map[string]string{}, Origins: map[string]Origin{}, Secrets: map[string]bool{}}
+}
+
+// FromCluster returns the env read from the cluster, with the values of
+// Secrets marked as secrets.
+func FromCluster(envs *kube.Envs) *Env {
+	e := New()
+	e.setCluster(envs)
+	return e
+}
+
+// Set sets the vars of m, overriding the ones already set.
+func (e *Env) Set(origin Origin, m map[string]string) {
+	for k, v := range m {
+		e.Vars[k] = v
+		e.Origins[k] = origin
+		delete(e.Secrets, k)
+	}
+}
+
+func (e *Env) setCluster(envs *kube.Envs) {
+	e.Set(OriginCluster, envs.Values)
+	for k, p := range envs.Provenance {
+		if _, ok := e.Vars[k]; ok && p == kube.ProvenanceSecret {
+			e.Secrets[k] = true
+		}
+	}
+}
+
+// IsSecret tells whether the var k holds a secret, known or guessed from
+// its name and value.
+func (e *Env) IsSecret(k string) bool {
+	return e.Secrets[k] || IsSecret(k, e.Vars[k])
+}
+
+// register registers the secrets of e with fn.AddSecrets, so that they
+// never end up in logs.
+func (e *Env) register() {
+	for k, v := range e.Vars {
+		if e.IsSecret(k) {
+			fn.AddSecrets(v)
+		}
+	}
+}
+
+// Keys returns the names of the vars, sorted.
+func (e *Env) Keys() []string {
+	return slices.Sorted(maps.Keys(e.Vars))
+}
+
+// Options selects the steps of Build.
+type Options struct {
+	// Refetch skips the env cache.
+	Refetch bool
+
+	// OnlyCluster leaves the overrides, the mounts and devbox out.
+	OnlyCluster bool
+
+	// Mount mirrors the volumes and the service account of the container as
+	// configured in loadEnv, the files are removed on exit so it only makes
+	// sense for a session.
+	Mount bool
+}
+
+// Build builds the env of the target t like `inkube dev` does.
+func Build(cfg *config.ConfigClient, t *config.Target, opts Options) (*Env, error) {
+	e := New()
+
+	if t.LoadEnv.Enabled {
+		envs, err := Cluster(t, kube.EnvOptions{Refetch: opts.Refetch})
+		if err != nil {
+			return nil, err
+		}
+		if err := filter(envs.Values, &t.LoadEnv); err != nil {
+			return nil, err
+		}
+		e.setCluster(envs)
+	}
+
+	if opts.OnlyCluster {
+		e.register()
+		return e, nil
+	}
+
+	if t.LoadEnv.Enabled {
+		for _, f := range t.LoadEnv.OverridesFrom {
+			m, err := overridesFrom(cfg.Dir(), f)
+			if err != nil {
+				return nil, err
+			}
+			e.Set(OriginOverride, m)
+
+			if secrets.IsEncrypted(f) {
+				for k := range m {
+					e.Secrets[k] = true
+				}
+			}
+		}
+
+		e.Set(OriginOverride, t.LoadEnv.Overrides)
+	}
+
+	if opts.Mount {
+		if err := mountFiles(cfg, t, e); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Devbox {
+		m, err := devbox.NewDevboxClient().ShellEnv(cfg.Dir())
+		if err != nil {
+			return nil, err
+		}
+		e.Set(OriginDevbox, m)
+	}
+
+	e.register()
+	return e, nil
+}
+
+// Cluster reads the env of the containers of t from the cluster, merged in
+// order. opts tells how the cache is used, the pod and the source are the
+// ones of t.
+func Cluster(t *config.Target, opts kube.EnvOptions) (*kube.Envs, error) {
+	workload, err := target(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return merge(t.LoadEnv.Containers, func(container string) (*kube.Envs, error) {
+		opts.Pod = kube.PodIdentity{
+			Name:   t.LoadEnv.Pod.Name,
+			Fields: t.LoadEnv.Pod.Fields,
+		}
+		opts.Source = kube.EnvSource(t.LoadEnv.Source)
+		return kubeClient().GetEnvs(t.Namespace, workload, container, opts)
+	})
+}
+
+// Cached returns the cached env of the containers of t, without reaching
+// the cluster.
+func Cached(t *config.Target) (*kube.Envs, error) {
+	workload, err := target(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return merge(t.LoadEnv.Containers, func(container string) (*kube.Envs, error) {
+		e, err := kubeClient().CachedEnv(t.Namespace, workload, container, kube.EnvSource(t.LoadEnv.Source))
+		if err != nil {
+			return nil, err
+		}
+		return &kube.Envs{Values: e.Envs, Provenance: e.Provenance}, nil
+	})
+}
+
+// merge reads the env of each container and merges them in order, a var set
+// in several containers takes the value of the last one.
+func merge(containers []string, read func(container string) (*kube.Envs, error)) (*kube.Envs, error) {
+	envs := &kube.Envs{Values: map[string]string{}, Provenance: map[string]kube.Provenance{}}
+	for _, c := range containers {
+		e, err := read(c)
+		if err != nil {
+			return nil, err
+		}
+		envs.Merge(e)
+	}
+	return envs, nil
+}
+
+// target returns the workload env vars of t are read from, once t points at
+// containers.
+func target(t *config.Target) (kube.WorkloadRef, error) {
+	kind, name := t.EnvWorkload()
+
+	please := "please run `inkube switch` to set the app name, namespace and containers"
+	if name == "" {
+		return kube.WorkloadRef{}, fn.Errorf("workload name is not set, %s", please)
+	}
+
+	if len(t.LoadEnv.Containers) == 0 {
+		return kube.WorkloadRef{}, fn.Errorf("containers are not set, %s", please)
+	}
+
+	if t.Namespace == "" {
+		return kube.WorkloadRef{}, fn.Errorf("namespace is not set, %s", please)
+	}
+
+	return kube.WorkloadRef{Kind: kube.WorkloadKind(kind), Name: name}, nil
+}
+
+// Only keeps the vars whose name matches one of the glob patterns, like
+// DB_* or PORT.
+func (e *Env) Only(patterns []string) error {
+	for k := range e.Vars {
+		keep := false
+		for _, p := range patterns {
+			ok, err := path.Match(p, k)
+			if err != nil {
+				return fn.Errorf("invalid key pattern %q: %w", p, err)
+			}
+			if ok {
+				keep = true
+				break
+			}
+		}
+
+		if !keep {
+			delete(e.Vars, k)
+			delete(e.Origins, k)
+		}
+	}
+
+	return nil
+}
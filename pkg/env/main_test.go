@@ -0,0 +1,56 @@
+package env
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+)
+
+func TestMerge(t *testing.T) {
+	containers := map[string]*kube.Envs{
+		"app": {
+			Values:     map[string]string{"SHARED": "app", "DB_PASSWORD": "s3cr3t", "PORT": "80"},
+			Provenance: map[string]kube.Provenance{"SHARED": kube.ProvenanceLiteral, "DB_PASSWORD": kube.ProvenanceSecret, "PORT": kube.ProvenanceLiteral},
+		},
+		"init:config": {
+			Values:     map[string]string{"SHARED": "init", "DB_PASSWORD": "plain"},
+			Provenance: map[string]kube.Provenance{"SHARED": kube.ProvenanceConfigMap, "DB_PASSWORD": kube.ProvenanceLiteral},
+		},
+	}
+
+	read := func(c string) (*kube.Envs, error) {
+		if e, ok := containers[c]; ok {
+			return e, nil
+		}
+		return nil, fn.Errorf("container %s not found", c)
+	}
+
+	got, err := merge([]string{"app", "init:config"}, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]string{"SHARED": "init", "DB_PASSWORD": "plain", "PORT": "80"}; !maps.Equal(got.Values, want) {
+		t.Errorf("got %v, want %v", got.Values, want)
+	}
+
+	// the provenance follows the value
+	if got.Provenance["DB_PASSWORD"] != kube.ProvenanceLiteral || got.Provenance["SHARED"] != kube.ProvenanceConfigMap {
+		t.Errorf("got provenance %v", got.Provenance)
+	}
+
+	got, _ = merge([]string{"init:config", "app"}, read)
+	if got.Values["SHARED"] != "app" || got.Provenance["DB_PASSWORD"] != kube.ProvenanceSecret {
+		t.Errorf("the last container doesn't win: %v %v", got.Values, got.Provenance)
+	}
+
+	if containers["app"].Values["SHARED"] != "app" {
+		t.Errorf("merge changed the env of a container")
+	}
+
+	if _, err := merge([]string{"app", "worker"}, read); err == nil {
+		t.Errorf("expected an error for a missing container")
+	}
+}
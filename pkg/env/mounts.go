@@ -0,0 +1,162 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/kube"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+)
+
+// mountFiles mirrors the volumes of the container and mints a token of its
+// service account, as configured, to a directory removed on exit, and
+// applies the rewrite rules of loadEnv.mounts.
+func mountFiles(cfg *config.ConfigClient, t *config.Target, e *Env) error {
+	mounts, sa := t.LoadEnv.Mounts, t.LoadEnv.ServiceAccount
+	if !mounts.Enabled && !sa.Enabled {
+		return nil
+	}
+
+	var expiration time.Duration
+	if sa.Expiration != "" {
+		d, err := time.ParseDuration(sa.Expiration)
+		if err != nil {
+			return fn.Errorf("invalid loadEnv.serviceAccount.expiration %q: %v", sa.Expiration, err)
+		}
+		expiration = d
+	}
+
+	root, err := kube.MountRoot()
+	if err != nil {
+		return err
+	}
+	fn.OnCleanup(func() {
+		os.RemoveAll(root)
+	})
+
+	workload, err := target(t)
+	if err != nil {
+		return err
+	}
+	kubeclient := kubeClient()
+
+	// the volumes are the ones of the first container, the main one, two
+	// containers may mount different volumes at the same path
+	if mounts.Enabled {
+		ms, err := kubeclient.MirrorVolumes(t.Namespace, workload, t.LoadEnv.Containers[0], kube.MountOptions{
+			Root: root,
+			Pod: kube.PodIdentity{
+				Name:   t.LoadEnv.Pod.Name,
+				Fields: t.LoadEnv.Pod.Fields,
+			},
+			Env: e.Vars,
+		})
+		if err != nil {
+			return err
+		}
+
+		fn.Log(text.Blue(fmt.Sprintf("[#] mirrored %d volume mounts to %s", len(ms), root)))
+	}
+
+	if sa.Enabled {
+		m, err := kubeclient.MountServiceAccount(t.Namespace, workload, root, expiration)
+		if err != nil {
+			return err
+		}
+
+		e.Set(OriginMount, m)
+	}
+
+	e.Set(OriginMount, map[string]string{"INKUBE_MOUNT_ROOT": root})
+
+	rewritten, err := rewriteMounts(root, cfg.Dir(), mounts.Rewrite, e.Vars)
+	if err != nil {
+		return err
+	}
+	e.Set(OriginMount, rewritten)
+
+	return nil
+}
+
+// rewriteMounts links the local path of every rule to the mirrored container
+// path, and returns the env values under a container path pointed to the
+// local one.
+func rewriteMounts(root, dir string, rules map[string]string, envs map[string]string) (map[string]string, error) {
+	// container paths are cleaned, /etc/config/ is the same rule as
+	// /etc/config
+	cleaned := make(map[string]string, len(rules))
+	for from, to := range rules {
+		cleaned[path.Clean(from)] = to
+	}
+	rules = cleaned
+
+	// longest first, so that /etc/config/app wins over /etc/config
+	froms := make([]string, 0, len(rules))
+	for from := range rules {
+		froms = append(froms, from)
+	}
+	slices.SortFunc(froms, func(a, b string) int {
+		return len(b) - len(a)
+	})
+
+	linked := map[string]string{}
+	for _, from := range froms {
+		to := rules[from]
+		if !filepath.IsAbs(to) {
+			to = filepath.Join(dir, to)
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(from))
+		if _, err := os.Stat(target); err != nil {
+			fn.Log(text.Yellow(fmt.Sprintf("[!] %s is not mirrored, not linking it to %s", from, to)))
+			continue
+		}
+
+		// links left by a session that didn't exit cleanly are replaced
+		if fi, err := os.Lstat(to); err == nil {
+			if fi.Mode()&os.ModeSymlink == 0 {
+				return nil, fn.Errorf("%s already exists, remove it or change loadEnv.mounts.rewrite", to)
+			}
+			if err := os.Remove(to); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			return nil, err
+		}
+
+		if err := os.Symlink(target, to); err != nil {
+			return nil, err
+		}
+		fn.OnCleanup(func() {
+			os.Remove(to)
+		})
+
+		linked[from] = to
+	}
+
+	rewritten := map[string]string{}
+	for k, v := range envs {
+		for _, from := range froms {
+			to, ok := linked[from]
+			if !ok {
+				continue
+			}
+
+			if v == from || strings.HasPrefix(v, strings.TrimSuffix(from, "/")+"/") {
+				rewritten[k] = to + v[len(from):]
+				break
+			}
+		}
+	}
+
+	return rewritten, nil
+}
@@ -0,0 +1,77 @@
+package env
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteMounts(t *testing.T) {
+	root, dir := t.TempDir(), t.TempDir()
+	for _, p := range []string{"etc/config", "etc/config/app", "var/run/secrets"} {
+		if err := os.MkdirAll(filepath.Join(root, p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := map[string]string{
+		"/etc/config/":     "cfg",
+		"/etc/config/app":  "app",
+		"/var/run/secrets": filepath.Join(dir, "secrets"),
+		"/not/mirrored":    "missing",
+	}
+
+	envs := map[string]string{
+		"CONFIG":      "/etc/config/app.yaml",
+		"CONFIG_DIR":  "/etc/config",
+		"APP":         "/etc/config/app/main.yaml",
+		"TOKEN":       "/var/run/secrets/token",
+		"CONFIGURED":  "/etc/configured",
+		"NOT_A_MOUNT": "/not/mirrored/file",
+	}
+
+	e := New()
+	e.Set(OriginCluster, envs)
+
+	rewritten, err := rewriteMounts(root, dir, rules, e.Vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the values under a mirrored path
+	want := map[string]string{
+		"CONFIG":     filepath.Join(dir, "cfg") + "/app.yaml",
+		"CONFIG_DIR": filepath.Join(dir, "cfg"),
+		"APP":        filepath.Join(dir, "app") + "/main.yaml",
+		"TOKEN":      filepath.Join(dir, "secrets") + "/token",
+	}
+	if !maps.Equal(rewritten, want) {
+		t.Errorf("got %v, want %v", rewritten, want)
+	}
+
+	// recorded the way mountFiles does
+	e.Set(OriginMount, rewritten)
+	for k, origin := range map[string]Origin{"CONFIG": OriginMount, "TOKEN": OriginMount, "CONFIGURED": OriginCluster, "NOT_A_MOUNT": OriginCluster} {
+		if e.Origins[k] != origin {
+			t.Errorf("%s comes from %s, want %s", k, e.Origins[k], origin)
+		}
+	}
+	if e.Vars["CONFIGURED"] != "/etc/configured" {
+		t.Errorf("CONFIGURED was rewritten to %s", e.Vars["CONFIGURED"])
+	}
+
+	for from, to := range map[string]string{"etc/config": "cfg", "etc/config/app": "app"} {
+		got, err := os.Readlink(filepath.Join(dir, to))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(root, from); got != want {
+			t.Errorf("%s links to %s, want %s", to, got, want)
+		}
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("a path that isn't mirrored was linked")
+	}
+}
@@ -0,0 +1,145 @@
+package env
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/secrets"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	"gopkg.in/yaml.v3"
+)
+
+// filter drops the vars of the cluster left out by loadEnv.include,
+// loadEnv.exclude and loadEnv.unset.
+func filter(envs map[string]string, l *config.LoadEnv) error {
+	include, err := compile("loadEnv.include", l.Include)
+	if err != nil {
+		return err
+	}
+
+	exclude, err := compile("loadEnv.exclude", l.Exclude)
+	if err != nil {
+		return err
+	}
+
+	for k := range envs {
+		if (len(include) > 0 && !matchAny(include, k)) || matchAny(exclude, k) {
+			delete(envs, k)
+			continue
+		}
+
+		for _, p := range l.Unset {
+			ok, err := path.Match(p, k)
+			if err != nil {
+				return fn.Errorf("invalid loadEnv.unset pattern %q: %w", p, err)
+			}
+			if ok {
+				delete(envs, k)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func compile(field string, patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fn.Errorf("invalid %s pattern %q: %w", field, p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// overridesFrom reads a file of loadEnv.overridesFrom, relative to dir. A
+// missing file is empty, they are often git-ignored. Encrypted files, see
+// package secrets, are decrypted with the age identities of the user.
+func overridesFrom(dir, file string) (map[string]string, error) {
+	p := file
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fn.Debug(text.Gray("skipping " + file + ", it doesn't exist"))
+			return nil, nil
+		}
+		return nil, fn.Errorf("failed to read loadEnv.overridesFrom %s: %w", file, err)
+	}
+
+	if secrets.IsEncrypted(file) {
+		if b, err = secrets.Decrypt(file, b); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := ParseOverrides(file, b)
+	if err != nil {
+		return nil, fn.Errorf("invalid loadEnv.overridesFrom %s: %w", file, err)
+	}
+
+	return m, nil
+}
+
+// ParseOverrides parses the decrypted content of an overrides file, a YAML
+// map for .yaml and .yml files and a dotenv file otherwise.
+func ParseOverrides(name string, b []byte) (map[string]string, error) {
+	switch filepath.Ext(secrets.Plain(name)) {
+	case ".yaml", ".yml":
+		return parseYAML(b)
+	}
+	return ParseDotenv(b)
+}
+
+// parseYAML parses a map of scalars, the values are kept as written so that
+// 1.10 doesn't become 1.1.
+func parseYAML(b []byte) (map[string]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	m := map[string]string{}
+	if doc.Kind == 0 {
+		return m, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fn.Errorf("expected a map of env vars")
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i].Value, root.Content[i+1]
+		if v.Kind != yaml.ScalarNode {
+			return nil, fn.Errorf("%s: expected a string, a number or a bool", k)
+		}
+
+		if v.Tag == "!!null" {
+			m[k] = ""
+			continue
+		}
+		m[k] = v.Value
+	}
+
+	return m, nil
+}
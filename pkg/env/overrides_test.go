@@ -0,0 +1,193 @@
+package env
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/abdheshnayak/inkube/pkg/config"
+	"github.com/abdheshnayak/inkube/pkg/secrets"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]string
+		err  bool
+	}{
+		{
+			name: "plain",
+			in:   "# comment\n\nA=1\nexport B = two words # comment\nC=\nD=a#b\n",
+			want: map[string]string{"A": "1", "B": "two words", "C": "", "D": "a#b"},
+		},
+		{
+			name: "quoted",
+			in:   "A=\"line\\nnext \\\"q\\\" \\$HOME\"\nB='lit\\n $X' # comment\nC=\"${KEEP}\"\r\n",
+			want: map[string]string{"A": "line\nnext \"q\" $HOME", "B": "lit\\n $X", "C": "${KEEP}"},
+		},
+		{
+			name: "multi-line",
+			in:   "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT=1",
+			want: map[string]string{"KEY": "-----BEGIN-----\nabc\n-----END-----", "NEXT": "1"},
+		},
+		{name: "unterminated", in: "A=\"abc\nB=1\n", err: true},
+		{name: "no value", in: "JUST_A_NAME\n", err: true},
+		{name: "bad name", in: "1A=x\n", err: true},
+		{name: "trailing", in: "A=\"x\" y\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotenv([]byte(tt.in))
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDotenvRoundTrip(t *testing.T) {
+	vars := map[string]string{"A": "it's \"q\" $x `y` \\", "B": "a\nb", "C": "", "D": "# not a comment"}
+
+	out, _, err := FormatDotenv.Format(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseDotenv([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(got, vars) {
+		t.Errorf("got %q, want %q", got, vars)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	envs := func() map[string]string {
+		return map[string]string{
+			"APP_PORT":                    "80",
+			"APP_DEBUG":                   "0",
+			"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector.prod:4317",
+			"OTEL_SERVICE_NAME":           "api",
+			"KUBERNETES_PORT":             "tcp://10.0.0.1:443",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		loadEnv config.LoadEnv
+		want    []string
+	}{
+		{
+			name:    "unset",
+			loadEnv: config.LoadEnv{Unset: []string{"OTEL_*", "APP_DEBUG"}},
+			want:    []string{"APP_PORT", "KUBERNETES_PORT"},
+		},
+		{
+			name:    "include",
+			loadEnv: config.LoadEnv{Include: []string{"^APP_", "^OTEL_SERVICE"}},
+			want:    []string{"APP_DEBUG", "APP_PORT", "OTEL_SERVICE_NAME"},
+		},
+		{
+			name:    "include and exclude",
+			loadEnv: config.LoadEnv{Include: []string{"^APP_", "^OTEL_"}, Exclude: []string{"EXPORTER"}, Unset: []string{"APP_DEBUG"}},
+			want:    []string{"APP_PORT", "OTEL_SERVICE_NAME"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := envs()
+			if err := filter(m, &tt.loadEnv); err != nil {
+				t.Fatal(err)
+			}
+
+			e := New()
+			e.Set(OriginCluster, m)
+			if got := e.Keys(); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := filter(envs(), &config.LoadEnv{Exclude: []string{"("}}); err == nil {
+		t.Errorf("expected an error for an invalid regular expression")
+	}
+}
+
+func TestOverridesFrom(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env.local"), []byte("A=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := overridesFrom(dir, ".env.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got["A"] != "1" {
+		t.Errorf("got %v", got)
+	}
+
+	if got, err := overridesFrom(dir, ".env.missing"); err != nil || len(got) != 0 {
+		t.Errorf("a missing file should be empty, got %v %v", got, err)
+	}
+}
+
+func TestOverridesFromEncrypted(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(keys, []byte(id.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY_FILE", keys)
+	rs := []*age.X25519Recipient{id.Recipient()}
+
+	dir := t.TempDir()
+	for name, plain := range map[string]string{
+		"shared.enc.yaml": "A: \"1.10\"\nB: true\nC:\n",
+		".env.shared.age": "D=4\n",
+	} {
+		b, err := secrets.Encrypt(name, []byte(plain), nil, rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := overridesFrom(dir, "shared.enc.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]string{"A": "1.10", "B": "true", "C": ""}; !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got, err := overridesFrom(dir, ".env.shared.age"); err != nil || got["D"] != "4" {
+		t.Errorf("got %v %v", got, err)
+	}
+}
@@ -6,15 +6,24 @@ import (
 	"os/exec"
 	"runtime"
 
+	"github.com/abdheshnayak/inkube/flags"
 	"github.com/martinlindhe/notify"
 )
 
 func Alert(name string, str ...interface{}) {
+	if !flags.Notifications {
+		return
+	}
+
 	if runtime.GOOS == "darwin" {
 		notify.Alert("inkube", name, fmt.Sprint(str...), "")
 	}
 	if runtime.GOOS == "linux" {
 		notification(name, fmt.Sprint(str...), "")
+		if !flags.Sound {
+			return
+		}
+
 		if err := exec.Command("paplay", "/usr/share/sounds/freedesktop/stereo/alarm-clock-elapsed.oga").Start(); err != nil {
 			PrintError(NewE(err, "error playing alert sound"))
 		}
@@ -22,6 +31,10 @@ func Alert(name string, str ...interface{}) {
 }
 
 func Notify(name string, str ...interface{}) {
+	if !flags.Notifications {
+		return
+	}
+
 	if runtime.GOOS == "darwin" {
 		notify.Notify("inkube", name, fmt.Sprint(str...), "")
 	}
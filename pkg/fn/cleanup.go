@@ -0,0 +1,30 @@
+package fn
+
+import "sync"
+
+var cleanups struct {
+	sync.Mutex
+	funcs []func()
+}
+
+// OnCleanup registers f to run when inkube exits, including on SIGINT and
+// SIGTERM, e.g. to remove the files of a session. Cleanups run once, last
+// registered first.
+func OnCleanup(f func()) {
+	cleanups.Lock()
+	defer cleanups.Unlock()
+
+	cleanups.funcs = append(cleanups.funcs, f)
+}
+
+// Cleanup runs the registered cleanups.
+func Cleanup() {
+	cleanups.Lock()
+	funcs := cleanups.funcs
+	cleanups.funcs = nil
+	cleanups.Unlock()
+
+	for i := len(funcs) - 1; i >= 0; i-- {
+		funcs[i]()
+	}
+}
@@ -1,6 +1,7 @@
 package fn
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/abdheshnayak/inkube/flags"
@@ -19,7 +20,7 @@ func NewE(err error, s ...string) error {
 }
 
 func Error(s string) error {
-	return wraperr(fmt.Errorf(s))
+	return wraperr(errors.New(s))
 }
 
 func Errorf(format string, args ...interface{}) error {
@@ -57,7 +58,7 @@ func PrintError(err error) {
 	}
 
 	if flags.IsDev() || flags.IsVerbose {
-		tracerr.Print(err)
+		stderr(tracerr.Sprint(err) + "\n")
 		return
 	}
 
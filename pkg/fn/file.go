@@ -0,0 +1,42 @@
+package fn
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic replaces path with b, readers never see a partial file: b
+// is written to a temporary file next to path, with mode perm, and renamed
+// over it. The temporary file is removed when anything fails.
+func WriteFileAtomic(path string, b []byte, perm fs.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+	if err := func() error {
+		defer f.Close()
+
+		if err := f.Chmod(perm); err != nil {
+			return err
+		}
+
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+
+		return f.Sync()
+	}(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
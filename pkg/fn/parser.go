@@ -33,3 +33,7 @@ func ParseKlFile(cmd *cobra.Command) string {
 
 	return ""
 }
+
+func WithProfile(cmd *cobra.Command) {
+	cmd.Flags().String("profile", "", "profile (named target) from inkube.yaml to use")
+}
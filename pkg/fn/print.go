@@ -20,7 +20,7 @@ func stderr(str string) {
 		defer spinner.Client.Resume()
 	}
 
-	_, _ = os.Stderr.WriteString(str)
+	_, _ = os.Stderr.WriteString(Redact(str))
 }
 
 func stdout(str string) {
@@ -33,7 +33,7 @@ func stdout(str string) {
 		defer spinner.Client.Resume()
 	}
 
-	_, _ = os.Stdout.WriteString(str)
+	_, _ = os.Stdout.WriteString(Redact(str))
 }
 
 func Log(str ...interface{}) {
@@ -0,0 +1,81 @@
+package fn
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/ui/spinner"
+)
+
+// Redacted replaces secret values in output.
+const Redacted = "********"
+
+// minSecretLen keeps short values like "1" or "true" from scrubbing
+// unrelated output.
+const minSecretLen = 4
+
+// redactor scrubs the secret values registered with AddSecrets.
+var redactor = struct {
+	sync.Mutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}{values: map[string]bool{}}
+
+// AddSecrets registers values that Redact replaces, like the values of the
+// Secrets a container reads its env from.
+func AddSecrets(values ...string) {
+	redactor.Lock()
+	defer redactor.Unlock()
+
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLen || redactor.values[v] {
+			continue
+		}
+
+		redactor.values[v] = true
+		redactor.replacer = nil
+	}
+}
+
+// Redact replaces the registered secrets in s, unless flags.Reveal is set.
+// Everything written by Log, Printf, PrintError and the spinner goes
+// through it.
+func Redact(s string) string {
+	if flags.Reveal {
+		return s
+	}
+
+	redactor.Lock()
+	defer redactor.Unlock()
+
+	if len(redactor.values) == 0 {
+		return s
+	}
+
+	if redactor.replacer == nil {
+		// longest first, so a secret containing another one is replaced
+		// as a whole
+		values := make([]string, 0, len(redactor.values))
+		for v := range redactor.values {
+			values = append(values, v)
+		}
+		slices.SortFunc(values, func(a, b string) int {
+			return len(b) - len(a)
+		})
+
+		pairs := make([]string, 0, 2*len(values))
+		for _, v := range values {
+			pairs = append(pairs, v, Redacted)
+		}
+		redactor.replacer = strings.NewReplacer(pairs...)
+	}
+
+	return redactor.replacer.Replace(s)
+}
+
+func init() {
+	spinner.Client.SetFilter(Redact)
+}
@@ -0,0 +1,34 @@
+package fn
+
+import (
+	"testing"
+
+	"github.com/abdheshnayak/inkube/flags"
+)
+
+func TestRedact(t *testing.T) {
+	AddSecrets("hunter2", "hunter2-long", "1", "  padded-secret\n")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "kubectl --token hunter2", want: "kubectl --token " + Redacted},
+		{in: "pass=hunter2-long;", want: "pass=" + Redacted + ";"},
+		{in: "replicas: 1", want: "replicas: 1"},
+		{in: "key padded-secret", want: "key " + Redacted},
+	}
+
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	flags.Reveal = true
+	defer func() { flags.Reveal = false }()
+
+	if got := Redact("hunter2"); got != "hunter2" {
+		t.Errorf("got %q with --reveal", got)
+	}
+}
@@ -0,0 +1,269 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodIdentity controls the pod Downward API values are read from.
+type PodIdentity struct {
+	// Name of the pod, a running pod of the workload is picked otherwise.
+	Name string
+
+	// Fields are values used for field paths (e.g. status.podIP) instead of
+	// the pod's, they make a live pod unnecessary when they cover every
+	// fieldRef.
+	Fields map[string]string
+}
+
+// downwardAPI resolves fieldRef and resourceFieldRef env vars the way the
+// kubelet does. The pod and its node are only fetched when a value needs
+// them.
+type downwardAPI struct {
+	ctx       context.Context
+	client    kubernetes.Interface
+	namespace string
+
+	// spec and selector of the workload the pod belongs to
+	spec     *corev1.PodSpec
+	selector *v1.LabelSelector
+
+	identity PodIdentity
+
+	pod  *corev1.Pod
+	node *corev1.Node
+}
+
+func newDownwardAPI(ctx context.Context, c kubernetes.Interface, namespace string, spec *corev1.PodSpec, selector *v1.LabelSelector, identity PodIdentity) *downwardAPI {
+	return &downwardAPI{
+		ctx:       ctx,
+		client:    c,
+		namespace: namespace,
+		spec:      spec,
+		selector:  selector,
+		identity:  identity,
+	}
+}
+
+func (d *downwardAPI) getPod() (*corev1.Pod, error) {
+	if d.pod != nil {
+		return d.pod, nil
+	}
+
+	if d.identity.Name != "" {
+		pod, err := d.client.CoreV1().Pods(d.namespace).Get(d.ctx, d.identity.Name, v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		d.pod = pod
+		return pod, nil
+	}
+
+	if d.selector == nil {
+		return nil, fmt.Errorf("workload has no selector to find its pods")
+	}
+
+	selector, err := v1.LabelSelectorAsSelector(d.selector)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := d.client.CoreV1().Pods(d.namespace).List(d.ctx, v1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	// ready pods first, then the newest
+	candidates := slices.DeleteFunc(pods.Items, func(p corev1.Pod) bool {
+		return p.Status.Phase != corev1.PodRunning || p.DeletionTimestamp != nil
+	})
+	slices.SortStableFunc(candidates, func(a, b corev1.Pod) int {
+		if ra, rb := isReady(&a), isReady(&b); ra != rb {
+			if ra {
+				return -1
+			}
+			return 1
+		}
+		return b.CreationTimestamp.Compare(a.CreationTimestamp.Time)
+	})
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no running pod found in %s to read downward API values from, set loadEnv.pod.fields in inkube.yaml to provide them", d.namespace)
+	}
+
+	d.pod = &candidates[0]
+	return d.pod, nil
+}
+
+func isReady(p *corev1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// field returns the value of a fieldRef.
+func (d *downwardAPI) field(path string) (string, error) {
+	if v, ok := d.identity.Fields[path]; ok {
+		return v, nil
+	}
+
+	pod, err := d.getPod()
+	if err != nil {
+		return "", err
+	}
+
+	if path, subscript, ok := splitSubscript(path); ok {
+		switch path {
+		case "metadata.annotations":
+			return pod.Annotations[subscript], nil
+		case "metadata.labels":
+			return pod.Labels[subscript], nil
+		}
+
+		return "", fmt.Errorf("unsupported fieldRef %s['%s']", path, subscript)
+	}
+
+	switch path {
+	case "metadata.name":
+		return pod.Name, nil
+	case "metadata.namespace":
+		return pod.Namespace, nil
+	case "metadata.uid":
+		return string(pod.UID), nil
+	case "metadata.labels":
+		return formatMap(pod.Labels), nil
+	case "metadata.annotations":
+		return formatMap(pod.Annotations), nil
+	case "spec.nodeName":
+		return pod.Spec.NodeName, nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	case "status.hostIP":
+		return pod.Status.HostIP, nil
+	case "status.hostIPs":
+		return joinIPs(pod.Status.HostIPs), nil
+	case "status.podIP":
+		return pod.Status.PodIP, nil
+	case "status.podIPs":
+		ips := make([]corev1.HostIP, 0, len(pod.Status.PodIPs))
+		for _, ip := range pod.Status.PodIPs {
+			ips = append(ips, corev1.HostIP{IP: ip.IP})
+		}
+		return joinIPs(ips), nil
+	}
+
+	return "", fmt.Errorf("unsupported fieldRef %s", path)
+}
+
+// splitSubscript splits metadata.labels['key'] into its path and key.
+func splitSubscript(path string) (string, string, bool) {
+	i := strings.Index(path, "['")
+	if i < 0 || !strings.HasSuffix(path, "']") {
+		return path, "", false
+	}
+
+	return path[:i], path[i+2 : len(path)-2], true
+}
+
+// formatMap formats labels and annotations like the kubelet: sorted
+// key="value" lines.
+func formatMap(m map[string]string) string {
+	lines := make([]string, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		lines = append(lines, fmt.Sprintf("%v=%q", k, m[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func joinIPs(ips []corev1.HostIP) string {
+	s := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		s = append(s, ip.IP)
+	}
+	return strings.Join(s, ",")
+}
+
+// resource returns the value of a resourceFieldRef of container, scaled by
+// its divisor and rounded up. Limits that aren't set default to the
+// allocatable resources of the node the pod runs on.
+func (d *downwardAPI) resource(container *corev1.Container, ref *corev1.ResourceFieldSelector) (string, error) {
+	if ref.ContainerName != "" && ref.ContainerName != container.Name {
+		i := slices.IndexFunc(d.spec.Containers, func(c corev1.Container) bool {
+			return c.Name == ref.ContainerName
+		})
+		if i < 0 {
+			return "", fmt.Errorf("container %s of resourceFieldRef not found", ref.ContainerName)
+		}
+		container = &d.spec.Containers[i]
+	}
+
+	divisor := ref.Divisor
+	if divisor.IsZero() {
+		divisor = resource.MustParse("1")
+	}
+
+	kind, name, ok := strings.Cut(ref.Resource, ".")
+	if !ok || (kind != "limits" && kind != "requests") {
+		return "", fmt.Errorf("unsupported resourceFieldRef %s", ref.Resource)
+	}
+
+	rn := corev1.ResourceName(name)
+	switch {
+	case rn == corev1.ResourceCPU, rn == corev1.ResourceMemory, rn == corev1.ResourceEphemeralStorage:
+	case strings.HasPrefix(name, corev1.ResourceHugePagesPrefix):
+	default:
+		return "", fmt.Errorf("unsupported resourceFieldRef %s", ref.Resource)
+	}
+
+	list := container.Resources.Requests
+	if kind == "limits" {
+		list = container.Resources.Limits
+	}
+
+	q, ok := list[rn]
+	if !ok && kind == "limits" && !strings.HasPrefix(name, corev1.ResourceHugePagesPrefix) {
+		allocatable, err := d.allocatable()
+		if err != nil {
+			return "", fmt.Errorf("%s is not set, and the node allocatable it defaults to can't be read: %w", ref.Resource, err)
+		}
+		q = allocatable[rn]
+	}
+
+	if rn == corev1.ResourceCPU {
+		return fmt.Sprint(int64(math.Ceil(float64(q.MilliValue()) / float64(divisor.MilliValue())))), nil
+	}
+
+	return fmt.Sprint(int64(math.Ceil(float64(q.Value()) / float64(divisor.Value())))), nil
+}
+
+func (d *downwardAPI) allocatable() (corev1.ResourceList, error) {
+	if d.node != nil {
+		return d.node.Status.Allocatable, nil
+	}
+
+	pod, err := d.getPod()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := d.client.CoreV1().Nodes().Get(d.ctx, pod.Spec.NodeName, v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	d.node = node
+	return node.Status.Allocatable, nil
+}
@@ -0,0 +1,206 @@
+package kube
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pod(name string, phase corev1.PodPhase, ready bool, age time.Duration) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              name,
+			Namespace:         testNamespace,
+			UID:               types.UID("uid-" + name),
+			Labels:            map[string]string{"app": "api", "tier": "backend"},
+			Annotations:       map[string]string{"team": "core"},
+			CreationTimestamp: v1.NewTime(time.Now().Add(-age)),
+		},
+		Spec: corev1.PodSpec{
+			NodeName:           "node-1",
+			ServiceAccountName: "api-sa",
+		},
+		Status: corev1.PodStatus{
+			Phase:      phase,
+			PodIP:      "10.0.0." + name[len(name)-1:],
+			PodIPs:     []corev1.PodIP{{IP: "10.0.0." + name[len(name)-1:]}, {IP: "fd00::1"}},
+			HostIP:     "192.168.0.1",
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func fieldEnv(name, path string) corev1.EnvVar {
+	return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: path}}}
+}
+
+func resourceEnv(name, res, divisor string) corev1.EnvVar {
+	ref := &corev1.ResourceFieldSelector{Resource: res}
+	if divisor != "" {
+		ref.Divisor = resource.MustParse(divisor)
+	}
+	return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{ResourceFieldRef: ref}}
+}
+
+func TestDownwardAPI(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: v1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("8Gi"),
+		}},
+	}
+
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("250m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("1500m"),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		identity PodIdentity
+		env      []corev1.EnvVar
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			name: "pod fields come from the newest ready running pod",
+			objects: []runtime.Object{
+				pod("api-1", corev1.PodRunning, true, time.Hour),
+				pod("api-2", corev1.PodRunning, true, time.Minute),
+				pod("api-3", corev1.PodRunning, false, time.Second),
+				pod("api-4", corev1.PodPending, false, 0),
+			},
+			env: []corev1.EnvVar{
+				fieldEnv("POD_NAME", "metadata.name"),
+				fieldEnv("POD_NAMESPACE", "metadata.namespace"),
+				fieldEnv("POD_IP", "status.podIP"),
+				fieldEnv("POD_IPS", "status.podIPs"),
+				fieldEnv("HOST_IP", "status.hostIP"),
+				fieldEnv("NODE_NAME", "spec.nodeName"),
+				fieldEnv("SA", "spec.serviceAccountName"),
+				fieldEnv("APP", "metadata.labels['app']"),
+				fieldEnv("LABELS", "metadata.labels"),
+				fieldEnv("TEAM", "metadata.annotations['team']"),
+			},
+			want: map[string]string{
+				"POD_NAME":      "api-2",
+				"POD_NAMESPACE": testNamespace,
+				"POD_IP":        "10.0.0.2",
+				"POD_IPS":       "10.0.0.2,fd00::1",
+				"HOST_IP":       "192.168.0.1",
+				"NODE_NAME":     "node-1",
+				"SA":            "api-sa",
+				"APP":           "api",
+				"LABELS":        "app=\"api\"\ntier=\"backend\"",
+				"TEAM":          "core",
+			},
+		},
+		{
+			name: "pod picked by name",
+			objects: []runtime.Object{
+				pod("api-1", corev1.PodRunning, true, time.Hour),
+				pod("api-2", corev1.PodRunning, true, time.Minute),
+			},
+			identity: PodIdentity{Name: "api-1"},
+			env:      []corev1.EnvVar{fieldEnv("POD_NAME", "metadata.name")},
+			want:     map[string]string{"POD_NAME": "api-1"},
+		},
+		{
+			name:     "faked fields need no pod",
+			identity: PodIdentity{Fields: map[string]string{"metadata.name": "local", "status.podIP": "127.0.0.1"}},
+			env: []corev1.EnvVar{
+				fieldEnv("POD_NAME", "metadata.name"),
+				fieldEnv("POD_IP", "status.podIP"),
+			},
+			want: map[string]string{"POD_NAME": "local", "POD_IP": "127.0.0.1"},
+		},
+		{
+			name:    "no running pod",
+			objects: []runtime.Object{pod("api-1", corev1.PodPending, false, 0)},
+			env:     []corev1.EnvVar{fieldEnv("POD_NAME", "metadata.name")},
+			wantErr: true,
+		},
+		{
+			name: "resources with divisors",
+			env: []corev1.EnvVar{
+				resourceEnv("CPU_REQUEST", "requests.cpu", ""),
+				resourceEnv("CPU_REQUEST_M", "requests.cpu", "1m"),
+				resourceEnv("CPU_LIMIT", "limits.cpu", ""),
+				resourceEnv("MEM_REQUEST", "requests.memory", ""),
+				resourceEnv("MEM_REQUEST_MI", "requests.memory", "1Mi"),
+				resourceEnv("STORAGE_REQUEST", "requests.ephemeral-storage", ""),
+			},
+			want: map[string]string{
+				"CPU_REQUEST":     "1",
+				"CPU_REQUEST_M":   "250",
+				"CPU_LIMIT":       "2",
+				"MEM_REQUEST":     "67108864",
+				"MEM_REQUEST_MI":  "64",
+				"STORAGE_REQUEST": "0",
+			},
+		},
+		{
+			name: "unset limits default to the node allocatable",
+			objects: []runtime.Object{
+				pod("api-1", corev1.PodRunning, true, time.Hour),
+				node,
+			},
+			env: []corev1.EnvVar{
+				resourceEnv("MEM_LIMIT", "limits.memory", "1Gi"),
+			},
+			want: map[string]string{"MEM_LIMIT": "8"},
+		},
+		{
+			name:    "unsupported resource",
+			env:     []corev1.EnvVar{resourceEnv("GPU", "limits.nvidia.com/gpu", "")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientset(tt.objects...)
+
+			container := corev1.Container{Name: "api", Env: tt.env, Resources: resources}
+			spec := &corev1.PodSpec{Containers: []corev1.Container{container}}
+			selector := &v1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+			d := newDownwardAPI(context.Background(), c, testNamespace, spec, selector, tt.identity)
+
+			got, _, err := resolveEnv(newObjects(context.Background(), c, testNamespace), &container, d)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !maps.Equal(got.Values, tt.want) {
+				t.Errorf("got %v, want %v", got.Values, tt.want)
+			}
+		})
+	}
+}
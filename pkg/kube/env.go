@@ -0,0 +1,324 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Provenance tells where the value of an env var comes from.
+type Provenance string
+
+const (
+	ProvenanceLiteral   Provenance = "literal"
+	ProvenanceConfigMap Provenance = "configmap"
+	ProvenanceSecret    Provenance = "secret"
+	ProvenanceDownward  Provenance = "downward"
+
+	// ProvenanceRuntime is for vars only set in the running container, by
+	// a webhook or the image, see EnvSourceExec.
+	ProvenanceRuntime Provenance = "runtime"
+)
+
+// Envs is the env of a container along with the provenance of every value.
+type Envs struct {
+	Values     map[string]string
+	Provenance map[string]Provenance
+}
+
+// Secrets returns the values read from Secrets.
+func (e *Envs) Secrets() []string {
+	var values []string
+	for k, p := range e.Provenance {
+		if p == ProvenanceSecret {
+			values = append(values, e.Values[k])
+		}
+	}
+	return values
+}
+
+// Merge sets the values of o on e along with their provenance, o wins.
+func (e *Envs) Merge(o *Envs) {
+	for k, v := range o.Values {
+		e.Values[k] = v
+		if p, ok := o.Provenance[k]; ok {
+			e.Provenance[k] = p
+		} else {
+			delete(e.Provenance, k)
+		}
+	}
+}
+
+// resolveEnv builds the env of container the way the kubelet does:
+//
+//   - envFrom sources are applied first, in order, with their prefix. Keys
+//     that aren't valid env var names are skipped and reported in warnings.
+//   - env entries are applied next, in order, so they win over envFrom.
+//     `$(VAR)` in their values is expanded against the variables defined
+//     before them, `$$` is a literal `$`, unknown references are kept as
+//     they are.
+//   - refs marked optional are skipped when their ConfigMap, Secret or key is
+//     missing, instead of failing.
+//   - fieldRef and resourceFieldRef are resolved through d, see downwardAPI.
+//
+// A literal value that references a secret is a secret too.
+func resolveEnv(objs *objects, container *corev1.Container, d *downwardAPI) (envs *Envs, warnings []string, err error) {
+	envs = &Envs{Values: map[string]string{}, Provenance: map[string]Provenance{}}
+
+	add := func(source, key, value string, p Provenance) {
+		if errs := validation.IsEnvVarName(key); len(errs) > 0 {
+			warnings = append(warnings, fmt.Sprintf("skipping %s from %s, it is not a valid env var name", key, source))
+			return
+		}
+		envs.Values[key] = value
+		envs.Provenance[key] = p
+	}
+
+	for _, envFrom := range container.EnvFrom {
+		switch {
+		case envFrom.ConfigMapRef != nil:
+			ref := envFrom.ConfigMapRef
+			cm, err := objs.configMap(ref.Name)
+			if err != nil {
+				if errors.IsNotFound(err) && isOptional(ref.Optional) {
+					continue
+				}
+				return nil, nil, err
+			}
+
+			for k, v := range cm.Data {
+				add("configmap "+ref.Name, envFrom.Prefix+k, v, ProvenanceConfigMap)
+			}
+
+		case envFrom.SecretRef != nil:
+			ref := envFrom.SecretRef
+			s, err := objs.secret(ref.Name)
+			if err != nil {
+				if errors.IsNotFound(err) && isOptional(ref.Optional) {
+					continue
+				}
+				return nil, nil, err
+			}
+
+			for k, v := range s.Data {
+				add("secret "+ref.Name, envFrom.Prefix+k, string(v), ProvenanceSecret)
+			}
+		}
+	}
+
+	for _, env := range container.Env {
+		var value string
+		provenance := ProvenanceLiteral
+
+		switch {
+		case env.Value != "":
+			value = expandEnv(env.Value, envs.Values)
+			if referencesSecret(env.Value, envs) {
+				provenance = ProvenanceSecret
+			}
+
+		case env.ValueFrom == nil:
+
+		case env.ValueFrom.ConfigMapKeyRef != nil:
+			ref := env.ValueFrom.ConfigMapKeyRef
+			cm, err := objs.configMap(ref.Name)
+			if err != nil {
+				if errors.IsNotFound(err) && isOptional(ref.Optional) {
+					continue
+				}
+				return nil, nil, err
+			}
+
+			v, ok := cm.Data[ref.Key]
+			if !ok {
+				if isOptional(ref.Optional) {
+					continue
+				}
+				return nil, nil, fmt.Errorf("couldn't find key %s in configmap %s/%s", ref.Key, objs.namespace, ref.Name)
+			}
+			value = v
+			provenance = ProvenanceConfigMap
+
+		case env.ValueFrom.SecretKeyRef != nil:
+			ref := env.ValueFrom.SecretKeyRef
+			s, err := objs.secret(ref.Name)
+			if err != nil {
+				if errors.IsNotFound(err) && isOptional(ref.Optional) {
+					continue
+				}
+				return nil, nil, err
+			}
+
+			v, ok := s.Data[ref.Key]
+			if !ok {
+				if isOptional(ref.Optional) {
+					continue
+				}
+				return nil, nil, fmt.Errorf("couldn't find key %s in secret %s/%s", ref.Key, objs.namespace, ref.Name)
+			}
+			value = string(v)
+			provenance = ProvenanceSecret
+
+		case env.ValueFrom.FieldRef != nil:
+			v, err := d.field(env.ValueFrom.FieldRef.FieldPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to resolve %s: %w", env.Name, err)
+			}
+			value = v
+			provenance = ProvenanceDownward
+
+		case env.ValueFrom.ResourceFieldRef != nil:
+			v, err := d.resource(container, env.ValueFrom.ResourceFieldRef)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to resolve %s: %w", env.Name, err)
+			}
+			value = v
+			provenance = ProvenanceDownward
+		}
+
+		envs.Values[env.Name] = value
+		envs.Provenance[env.Name] = provenance
+	}
+
+	return envs, warnings, nil
+}
+
+// referencesSecret tells whether the `$(VAR)` references of s expand a
+// secret of envs.
+func referencesSecret(s string, envs *Envs) bool {
+	for {
+		i := strings.Index(s, "$(")
+		if i < 0 {
+			return false
+		}
+
+		name, rest, ok := strings.Cut(s[i+2:], ")")
+		if !ok {
+			return false
+		}
+
+		if envs.Provenance[name] == ProvenanceSecret {
+			return true
+		}
+		s = rest
+	}
+}
+
+// objects fetches the ConfigMaps and Secrets a pod references, once each.
+type objects struct {
+	ctx       context.Context
+	client    kubernetes.Interface
+	namespace string
+
+	configMaps map[string]*corev1.ConfigMap
+	secrets    map[string]*corev1.Secret
+
+	// versions has the resourceVersion of every object fetched, by
+	// kind/name, empty for objects that don't exist
+	versions map[string]string
+}
+
+func newObjects(ctx context.Context, c kubernetes.Interface, namespace string) *objects {
+	return &objects{
+		ctx:        ctx,
+		client:     c,
+		namespace:  namespace,
+		configMaps: map[string]*corev1.ConfigMap{},
+		secrets:    map[string]*corev1.Secret{},
+		versions:   map[string]string{},
+	}
+}
+
+func (o *objects) configMap(name string) (*corev1.ConfigMap, error) {
+	if cm, ok := o.configMaps[name]; ok {
+		return cm, nil
+	}
+
+	cm, err := o.client.CoreV1().ConfigMaps(o.namespace).Get(o.ctx, name, v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			o.versions["configmap/"+name] = ""
+		}
+		return nil, err
+	}
+
+	o.configMaps[name] = cm
+	o.versions["configmap/"+name] = cm.ResourceVersion
+	return cm, nil
+}
+
+func (o *objects) secret(name string) (*corev1.Secret, error) {
+	if s, ok := o.secrets[name]; ok {
+		return s, nil
+	}
+
+	s, err := o.client.CoreV1().Secrets(o.namespace).Get(o.ctx, name, v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			o.versions["secret/"+name] = ""
+		}
+		return nil, err
+	}
+
+	o.secrets[name] = s
+	o.versions["secret/"+name] = s.ResourceVersion
+	return s, nil
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// expandEnv expands `$(VAR)` references in s like the kubelet does: `$$` is
+// an escaped `$`, and references to unknown variables are left untouched.
+func expandEnv(s string, vars map[string]string) string {
+	var buf strings.Builder
+	checkpoint := 0
+	for cursor := 0; cursor < len(s); cursor++ {
+		if s[cursor] != '$' || cursor+1 >= len(s) {
+			continue
+		}
+
+		buf.WriteString(s[checkpoint:cursor])
+
+		read, advance := readVariable(s[cursor+1:], vars)
+		buf.WriteString(read)
+
+		cursor += advance
+		checkpoint = cursor + 1
+	}
+
+	return buf.String() + s[checkpoint:]
+}
+
+// readVariable reads what follows a `$` and returns its expansion, along
+// with the number of bytes consumed.
+func readVariable(s string, vars map[string]string) (string, int) {
+	switch s[0] {
+	case '$':
+		return "$", 1
+	case '(':
+		for i := 1; i < len(s); i++ {
+			if s[i] != ')' {
+				continue
+			}
+
+			name := s[1:i]
+			if v, ok := vars[name]; ok {
+				return v, i + 1
+			}
+			return "$(" + name + ")", i + 1
+		}
+
+		// not terminated, taken literally
+		return "$(", 1
+	default:
+		return "$" + s[:1], 1
+	}
+}
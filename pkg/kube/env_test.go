@@ -0,0 +1,382 @@
+package kube
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "app"
+
+func configMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Data:       data,
+	}
+}
+
+func secret(name string, data map[string]string) *corev1.Secret {
+	s := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Data:       map[string][]byte{},
+	}
+
+	for k, v := range data {
+		s.Data[k] = []byte(v)
+	}
+
+	return s
+}
+
+func optional() *bool {
+	b := true
+	return &b
+}
+
+func cmRef(name string, opt *bool) *corev1.ConfigMapEnvSource {
+	return &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Optional: opt}
+}
+
+func secretRef(name string, opt *bool) *corev1.SecretEnvSource {
+	return &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Optional: opt}
+}
+
+func cmKey(name, key string, opt *bool) *corev1.EnvVarSource {
+	return &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		Key:                  key,
+		Optional:             opt,
+	}}
+}
+
+func secretKey(name, key string, opt *bool) *corev1.EnvVarSource {
+	return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		Key:                  key,
+		Optional:             opt,
+	}}
+}
+
+func TestResolveEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		container corev1.Container
+		want      map[string]string
+		warnings  int
+		wantErr   bool
+	}{
+		{
+			name: "env wins over envFrom",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"A": "from-cm", "B": "from-cm"}),
+			},
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: cmRef("cm", nil)}},
+				Env:     []corev1.EnvVar{{Name: "A", Value: "from-env"}},
+			},
+			want: map[string]string{"A": "from-env", "B": "from-cm"},
+		},
+		{
+			name: "later envFrom sources win",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"A": "from-cm"}),
+				secret("s", map[string]string{"A": "from-secret"}),
+			},
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{
+					{ConfigMapRef: cmRef("cm", nil)},
+					{SecretRef: secretRef("s", nil)},
+				},
+			},
+			want: map[string]string{"A": "from-secret"},
+		},
+		{
+			name: "later env entries win",
+			container: corev1.Container{
+				Env: []corev1.EnvVar{{Name: "A", Value: "1"}, {Name: "A", Value: "2"}},
+			},
+			want: map[string]string{"A": "2"},
+		},
+		{
+			name: "envFrom prefix",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"HOST": "db"}),
+				secret("s", map[string]string{"PASSWORD": "hunter2"}),
+			},
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{
+					{Prefix: "DB_", ConfigMapRef: cmRef("cm", nil)},
+					{Prefix: "DB_", SecretRef: secretRef("s", nil)},
+				},
+			},
+			want: map[string]string{"DB_HOST": "db", "DB_PASSWORD": "hunter2"},
+		},
+		{
+			name: "invalid keys are skipped",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"OK": "1", "1BAD": "2", "with space": "3"}),
+			},
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: cmRef("cm", nil)}},
+			},
+			want:     map[string]string{"OK": "1"},
+			warnings: 2,
+		},
+		{
+			name: "prefix makes a key valid",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"1ST": "x"}),
+			},
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{Prefix: "P_", ConfigMapRef: cmRef("cm", nil)}},
+			},
+			want: map[string]string{"P_1ST": "x"},
+		},
+		{
+			name: "optional missing envFrom sources are skipped",
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{
+					{ConfigMapRef: cmRef("missing", optional())},
+					{SecretRef: secretRef("missing", optional())},
+				},
+				Env: []corev1.EnvVar{{Name: "A", Value: "1"}},
+			},
+			want: map[string]string{"A": "1"},
+		},
+		{
+			name: "missing configmap fails",
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: cmRef("missing", nil)}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing secret fails",
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{SecretRef: secretRef("missing", nil)}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "key refs",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"host": "db"}),
+				secret("s", map[string]string{"password": "hunter2"}),
+			},
+			container: corev1.Container{
+				Env: []corev1.EnvVar{
+					{Name: "HOST", ValueFrom: cmKey("cm", "host", nil)},
+					{Name: "PASSWORD", ValueFrom: secretKey("s", "password", nil)},
+				},
+			},
+			want: map[string]string{"HOST": "db", "PASSWORD": "hunter2"},
+		},
+		{
+			name: "optional missing key refs are not set",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{}),
+				secret("s", map[string]string{}),
+			},
+			container: corev1.Container{
+				Env: []corev1.EnvVar{
+					{Name: "A", ValueFrom: cmKey("cm", "a", optional())},
+					{Name: "B", ValueFrom: secretKey("s", "b", optional())},
+					{Name: "C", ValueFrom: cmKey("missing", "c", optional())},
+					{Name: "D", ValueFrom: secretKey("missing", "d", optional())},
+				},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "missing configmap key fails",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{}),
+			},
+			container: corev1.Container{
+				Env: []corev1.EnvVar{{Name: "A", ValueFrom: cmKey("cm", "a", nil)}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing secret key fails",
+			objects: []runtime.Object{
+				secret("s", map[string]string{}),
+			},
+			container: corev1.Container{
+				Env: []corev1.EnvVar{{Name: "A", ValueFrom: secretKey("s", "a", nil)}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty value is set",
+			container: corev1.Container{
+				Env: []corev1.EnvVar{{Name: "A"}},
+			},
+			want: map[string]string{"A": ""},
+		},
+		{
+			name: "dependent variables",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"HOST": "db"}),
+			},
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: cmRef("cm", nil)}},
+				Env: []corev1.EnvVar{
+					{Name: "PORT", Value: "5432"},
+					{Name: "URL", Value: "postgres://$(HOST):$(PORT)/app"},
+				},
+			},
+			want: map[string]string{"HOST": "db", "PORT": "5432", "URL": "postgres://db:5432/app"},
+		},
+		{
+			name: "references to later or unknown variables are kept",
+			container: corev1.Container{
+				Env: []corev1.EnvVar{
+					{Name: "A", Value: "$(B)-$(NOPE)"},
+					{Name: "B", Value: "b"},
+				},
+			},
+			want: map[string]string{"A": "$(B)-$(NOPE)", "B": "b"},
+		},
+		{
+			name: "escapes and unterminated references",
+			container: corev1.Container{
+				Env: []corev1.EnvVar{
+					{Name: "A", Value: "a"},
+					{Name: "ESCAPED", Value: "$$(A)"},
+					{Name: "DOLLARS", Value: "$$$$"},
+					{Name: "OPEN", Value: "$(A"},
+					{Name: "PLAIN", Value: "$A$"},
+				},
+			},
+			want: map[string]string{"A": "a", "ESCAPED": "$(A)", "DOLLARS": "$$", "OPEN": "$(A", "PLAIN": "$A$"},
+		},
+		{
+			name: "values from refs are not expanded",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"raw": "$(A)"}),
+			},
+			container: corev1.Container{
+				Env: []corev1.EnvVar{
+					{Name: "A", Value: "a"},
+					{Name: "RAW", ValueFrom: cmKey("cm", "raw", nil)},
+				},
+			},
+			want: map[string]string{"A": "a", "RAW": "$(A)"},
+		},
+		{
+			name: "self reference uses the previous value",
+			objects: []runtime.Object{
+				configMap("cm", map[string]string{"PATH": "/bin"}),
+			},
+			container: corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: cmRef("cm", nil)}},
+				Env:     []corev1.EnvVar{{Name: "PATH", Value: "/app/bin:$(PATH)"}},
+			},
+			want: map[string]string{"PATH": "/app/bin:/bin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientset(tt.objects...)
+
+			got, warnings, err := resolveEnv(newObjects(context.Background(), c, testNamespace), &tt.container, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !maps.Equal(got.Values, tt.want) {
+				t.Errorf("got %v, want %v", got.Values, tt.want)
+			}
+
+			if len(warnings) != tt.warnings {
+				t.Errorf("got %d warnings (%v), want %d", len(warnings), warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	c := fake.NewClientset(
+		configMap("cm", map[string]string{"LOG_LEVEL": "info"}),
+		secret("db", map[string]string{"PASSWORD": "hunter2", "USER": "app"}),
+	)
+
+	container := corev1.Container{
+		EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: cmRef("cm", nil)}, {SecretRef: secretRef("db", nil), Prefix: "DB_"}},
+		Env: []corev1.EnvVar{
+			{Name: "PASSWORD", ValueFrom: secretKey("db", "PASSWORD", nil)},
+			{Name: "LEVEL", ValueFrom: cmKey("cm", "LOG_LEVEL", nil)},
+			{Name: "DSN", Value: "postgres://$(DB_USER):$(PASSWORD)@db"},
+			{Name: "GREETING", Value: "hello $(LOG_LEVEL)"},
+		},
+	}
+
+	got, _, err := resolveEnv(newObjects(context.Background(), c, testNamespace), &container, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Provenance{
+		"LOG_LEVEL":   ProvenanceConfigMap,
+		"DB_PASSWORD": ProvenanceSecret,
+		"DB_USER":     ProvenanceSecret,
+		"PASSWORD":    ProvenanceSecret,
+		"LEVEL":       ProvenanceConfigMap,
+		"DSN":         ProvenanceSecret,
+		"GREETING":    ProvenanceLiteral,
+	}
+
+	if !maps.Equal(got.Provenance, want) {
+		t.Errorf("got %v, want %v", got.Provenance, want)
+	}
+
+	secrets := got.Secrets()
+	slices.Sort(secrets)
+	if want := []string{"app", "hunter2", "hunter2", "postgres://app:hunter2@db"}; !slices.Equal(secrets, want) {
+		t.Errorf("secrets: got %v, want %v", secrets, want)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	vars := map[string]string{"A": "a", "EMPTY": ""}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: "plain", want: "plain"},
+		{in: "$(A)", want: "a"},
+		{in: "x$(A)y$(A)z", want: "xayaz"},
+		{in: "$(EMPTY)", want: ""},
+		{in: "$(B)", want: "$(B)"},
+		{in: "$$(A)", want: "$(A)"},
+		{in: "$$$(A)", want: "$a"},
+		{in: "$(A", want: "$(A"},
+		{in: "$", want: "$"},
+		{in: "a$", want: "a$"},
+		{in: "$()", want: "$()"},
+	}
+
+	for _, tt := range tests {
+		if got := expandEnv(tt.in, vars); got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
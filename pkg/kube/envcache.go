@@ -0,0 +1,164 @@
+package kube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abdheshnayak/inkube/pkg/cache"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+)
+
+// EnvCacheEntry is the env of a container as cached by GetEnvs, along with
+// what is needed to tell whether it is still up to date.
+type EnvCacheEntry struct {
+	// Server and Context identify the cluster the env was read from.
+	Server  string
+	Context string
+
+	Namespace string
+	Workload  string
+	Container string
+	Source    EnvSource
+
+	// Versions has the resourceVersion of the workload and of every object
+	// the env was read from, by kind/name. Objects that didn't exist have
+	// an empty version.
+	Versions map[string]string
+
+	Time       time.Time
+	Envs       map[string]string
+	Provenance map[string]Provenance
+}
+
+func (e *EnvCacheEntry) envs() *Envs {
+	return &Envs{Values: e.Envs, Provenance: e.Provenance}
+}
+
+// EnvCacheSuffix ends the name of the cache files of GetEnvs.
+const EnvCacheSuffix = ".secret.cache"
+
+// IsEnvCache tells whether the cache file name holds an env cached by
+// GetEnvs.
+func IsEnvCache(name string) bool {
+	return strings.HasSuffix(name, EnvCacheSuffix)
+}
+
+// ReadEnvCache reads the env cached in the cache file name.
+func ReadEnvCache(name string) (*EnvCacheEntry, error) {
+	var e EnvCacheEntry
+	if err := cache.Inspect(name, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// envCacheName names the cache file of an env, hashed so that it doesn't
+// leak the names and can't collide across clusters.
+func envCacheName(e *EnvCacheEntry) string {
+	h := sha256.Sum256([]byte(strings.Join([]string{e.Server, e.Context, e.Namespace, e.Workload, e.Container, string(e.Source)}, "\x00")))
+	return "env-" + hex.EncodeToString(h[:12]) + EnvCacheSuffix
+}
+
+// envCacheEntry returns the entry the env of a container is cached in, before
+// it is read.
+func (c *Client) envCacheEntry(namespace string, workload WorkloadRef, container string, source EnvSource) *EnvCacheEntry {
+	if source == "" {
+		source = EnvSourceSpec
+	}
+
+	server, kubeContext := c.identity()
+	return &EnvCacheEntry{
+		Server:    server,
+		Context:   kubeContext,
+		Namespace: namespace,
+		Workload:  workload.String(),
+		Container: container,
+		Source:    source,
+	}
+}
+
+// CachedEnv returns the cached env of a container as it is, without checking
+// whether it is up to date.
+func (c *Client) CachedEnv(namespace string, workload WorkloadRef, container string, source EnvSource) (*EnvCacheEntry, error) {
+	e, err := ReadEnvCache(envCacheName(c.envCacheEntry(namespace, workload, container, source)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("the env of %s/%s %s is not cached", namespace, workload, container)
+		}
+		return nil, err
+	}
+
+	fn.AddSecrets(e.envs().Secrets()...)
+	return e, nil
+}
+
+// identity returns the API server and the kube context the client talks to.
+func (c *Client) identity() (server, context string) {
+	server = c.config.Host
+
+	// empty in-cluster
+	if raw, err := kubeConfig().RawConfig(); err == nil {
+		context, _ = currentContext(raw)
+	}
+
+	return server, context
+}
+
+var resources = map[string]schema.GroupVersionResource{
+	string(KindDeployment):  {Group: "apps", Version: "v1", Resource: "deployments"},
+	string(KindStatefulSet): {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	string(KindDaemonSet):   {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	string(KindReplicaSet):  {Group: "apps", Version: "v1", Resource: "replicasets"},
+	string(KindJob):         {Group: "batch", Version: "v1", Resource: "jobs"},
+	string(KindCronJob):     {Group: "batch", Version: "v1", Resource: "cronjobs"},
+	string(KindPod):         {Version: "v1", Resource: "pods"},
+	"configmap":             {Version: "v1", Resource: "configmaps"},
+	"secret":                {Version: "v1", Resource: "secrets"},
+}
+
+// upToDate tells whether the objects e was read from are unchanged. Only
+// their metadata is fetched, it is cheap and needs no access to the data of
+// secrets.
+func upToDate(ctx context.Context, mc metadata.Interface, e *EnvCacheEntry) (bool, error) {
+	for ref, version := range e.Versions {
+		kind, name, _ := strings.Cut(ref, "/")
+		gvr, ok := resources[kind]
+		if !ok {
+			return false, nil
+		}
+
+		m, err := mc.Resource(gvr).Namespace(e.Namespace).Get(ctx, name, v1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				if version != "" {
+					return false, nil
+				}
+				continue
+			}
+			return false, err
+		}
+
+		if m.ResourceVersion != version {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (c *Client) metadataClient() (metadata.Interface, error) {
+	mc, err := metadata.NewForConfig(c.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a metadata client: %w", err)
+	}
+	return mc, nil
+}
@@ -0,0 +1,89 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func partial(apiVersion, kind, name, version string) *v1.PartialObjectMetadata {
+	return &v1.PartialObjectMetadata{
+		TypeMeta:   v1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: testNamespace, ResourceVersion: version},
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	scheme := metadatafake.NewTestScheme()
+	if err := v1.AddMetaToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	mc := metadatafake.NewSimpleMetadataClient(scheme,
+		partial("apps/v1", "Deployment", "api", "10"),
+		partial("v1", "ConfigMap", "app", "20"),
+		partial("v1", "Secret", "db", "30"),
+	)
+
+	tests := []struct {
+		name     string
+		versions map[string]string
+		want     bool
+	}{
+		{
+			name:     "unchanged",
+			versions: map[string]string{"deployment/api": "10", "configmap/app": "20", "secret/db": "30"},
+			want:     true,
+		},
+		{
+			name:     "workload changed",
+			versions: map[string]string{"deployment/api": "9", "configmap/app": "20"},
+		},
+		{
+			name:     "secret changed",
+			versions: map[string]string{"deployment/api": "10", "secret/db": "29"},
+		},
+		{
+			name:     "object deleted",
+			versions: map[string]string{"configmap/gone": "5"},
+		},
+		{
+			name:     "still missing optional object",
+			versions: map[string]string{"deployment/api": "10", "configmap/optional": ""},
+			want:     true,
+		},
+		{
+			name:     "optional object created",
+			versions: map[string]string{"configmap/app": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := upToDate(context.Background(), mc, &EnvCacheEntry{Namespace: testNamespace, Versions: tt.versions})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvCacheName(t *testing.T) {
+	e := EnvCacheEntry{Server: "https://a:6443", Context: "a", Namespace: "app", Workload: "deployment/api", Container: "api", Source: EnvSourceSpec}
+	other := e
+	other.Server, other.Context = "https://b:6443", "b"
+
+	if envCacheName(&e) == envCacheName(&other) {
+		t.Errorf("entries of two clusters share a cache file")
+	}
+
+	if envCacheName(&e) != envCacheName(&e) {
+		t.Errorf("cache names are not stable")
+	}
+}
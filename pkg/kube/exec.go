@@ -0,0 +1,146 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// EnvSource is where the env of the container is read from.
+type EnvSource string
+
+const (
+	// EnvSourceSpec resolves the env declared in the pod template.
+	EnvSourceSpec EnvSource = "spec"
+
+	// EnvSourceExec also reads the env of a running container, which has
+	// the vars injected by mutating webhooks (Vault agent, Istio, Datadog
+	// admission, ...) that the pod template doesn't.
+	EnvSourceExec EnvSource = "exec"
+)
+
+// envCommands read the env of a running container, the environ of its main
+// process first (NUL separated, and set by the entrypoint rather than by
+// exec), then env for images without cat.
+var envCommands = []struct {
+	command []string
+	sep     string
+}{
+	{command: []string{"cat", "/proc/1/environ"}, sep: "\x00"},
+	{command: []string{"env"}, sep: "\n"},
+}
+
+// runtimeOnlyIgnored are vars set by the image or the container runtime
+// that don't make sense outside of the container.
+var runtimeOnlyIgnored = []string{"HOME", "HOSTNAME", "OLDPWD", "PATH", "PWD", "SHLVL", "TERM", "_"}
+
+// execEnv reads the env of container in a running pod.
+func (c *Client) execEnv(ctx context.Context, pod *corev1.Pod, container string) (map[string]string, error) {
+	var errs []string
+	for _, ec := range envCommands {
+		out, err := c.exec(ctx, pod, container, ec.command)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", strings.Join(ec.command, " "), err))
+			continue
+		}
+
+		return parseEnv(out, ec.sep), nil
+	}
+
+	return nil, fmt.Errorf("failed to read the env of %s/%s: %s", pod.Name, container, strings.Join(errs, "; "))
+}
+
+func (c *Client) exec(ctx context.Context, pod *corev1.Pod, container string, command []string) ([]byte, error) {
+	req := c.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			return nil, fmt.Errorf("%w: %s", err, s)
+		}
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// parseEnv parses KEY=value entries separated by sep. With newlines, lines
+// without a = continue the value of the previous entry.
+func parseEnv(b []byte, sep string) map[string]string {
+	envs := map[string]string{}
+
+	last := ""
+	for _, entry := range strings.Split(strings.TrimSuffix(string(b), sep), sep) {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok || k == "" {
+			if last != "" && sep == "\n" {
+				envs[last] += "\n" + entry
+			}
+			continue
+		}
+
+		envs[k] = v
+		last = k
+	}
+
+	return envs
+}
+
+// serviceLink matches the docker link style vars the kubelet sets for every
+// service of the namespace (API_SERVICE_HOST, API_PORT_80_TCP_ADDR, ...).
+var serviceLink = regexp.MustCompile(`_(SERVICE_HOST|SERVICE_PORT(_[A-Z0-9_]+)?|PORT_\d+_(TCP|UDP|SCTP)(_(PROTO|PORT|ADDR))?)$`)
+
+func isServiceLink(k, v string) bool {
+	if strings.HasSuffix(k, "_PORT") {
+		return strings.HasPrefix(v, "tcp://") || strings.HasPrefix(v, "udp://") || strings.HasPrefix(v, "sctp://")
+	}
+	return serviceLink.MatchString(k)
+}
+
+// mergeRuntimeEnv merges the env read from a running container over the
+// declared one, and returns the names of the vars only set at runtime,
+// leaving out service links. Values changed at runtime are of
+// ProvenanceRuntime, unless they replace a secret.
+func mergeRuntimeEnv(envs *Envs, runtime map[string]string) []string {
+	var only []string
+	for k, v := range runtime {
+		if slices.Contains(runtimeOnlyIgnored, k) {
+			continue
+		}
+
+		declared, ok := envs.Values[k]
+		if !ok && !isServiceLink(k, v) {
+			only = append(only, k)
+		}
+
+		if !ok || (declared != v && envs.Provenance[k] != ProvenanceSecret) {
+			envs.Provenance[k] = ProvenanceRuntime
+		}
+		envs.Values[k] = v
+	}
+
+	slices.Sort(only)
+	return only
+}
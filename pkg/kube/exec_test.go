@@ -0,0 +1,87 @@
+package kube
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		sep  string
+		want map[string]string
+	}{
+		{
+			name: "environ",
+			in:   "A=1\x00B=x=y\x00EMPTY=\x00MULTI=a\nb\x00",
+			sep:  "\x00",
+			want: map[string]string{"A": "1", "B": "x=y", "EMPTY": "", "MULTI": "a\nb"},
+		},
+		{
+			name: "env output",
+			in:   "A=1\nMULTI=a\nb\nB=2\n",
+			sep:  "\n",
+			want: map[string]string{"A": "1", "MULTI": "a\nb", "B": "2"},
+		},
+		{
+			name: "empty",
+			in:   "",
+			sep:  "\x00",
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseEnv([]byte(tt.in), tt.sep); !maps.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeRuntimeEnv(t *testing.T) {
+	envs := &Envs{
+		Values:     map[string]string{"A": "declared", "B": "b", "S": "from-secret"},
+		Provenance: map[string]Provenance{"A": ProvenanceLiteral, "B": ProvenanceConfigMap, "S": ProvenanceSecret},
+	}
+	runtime := map[string]string{
+		"A":                    "runtime",
+		"B":                    "b",
+		"VAULT_TOKEN":          "s.xyz",
+		"DD_AGENT_HOST":        "10.0.0.1",
+		"PATH":                 "/usr/bin",
+		"HOSTNAME":             "api-1",
+		"API_SERVICE_HOST":     "10.96.0.10",
+		"API_PORT":             "tcp://10.96.0.10:80",
+		"API_PORT_80_TCP_ADDR": "10.96.0.10",
+		"DB_PORT":              "5432",
+		"S":                    "rotated",
+	}
+
+	only := mergeRuntimeEnv(envs, runtime)
+
+	if want := []string{"DB_PORT", "DD_AGENT_HOST", "VAULT_TOKEN"}; !slices.Equal(only, want) {
+		t.Errorf("runtime only: got %v, want %v", only, want)
+	}
+
+	if envs.Values["A"] != "runtime" {
+		t.Errorf("runtime values should win, got A=%q", envs.Values["A"])
+	}
+
+	if _, ok := envs.Values["PATH"]; ok {
+		t.Errorf("PATH of the container should not be merged")
+	}
+
+	if envs.Values["API_SERVICE_HOST"] != "10.96.0.10" {
+		t.Errorf("service links should be merged")
+	}
+
+	for k, want := range map[string]Provenance{"A": ProvenanceRuntime, "B": ProvenanceConfigMap, "S": ProvenanceSecret, "VAULT_TOKEN": ProvenanceRuntime} {
+		if got := envs.Provenance[k]; got != want {
+			t.Errorf("%s: got provenance %s, want %s", k, got, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package kube
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abdheshnayak/inkube/flags"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// IsPinned tells whether the context or kubeconfig were pinned by inkube.yaml
+// or the command line.
+func IsPinned() bool {
+	return flags.KubeContext != "" || flags.Kubeconfig != ""
+}
+
+// kubeConfig loads the kubeconfig honouring the pinned context and file,
+// $KUBECONFIG and ~/.kube/config are used otherwise.
+func kubeConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if flags.Kubeconfig != "" {
+		loadingRules.ExplicitPath = flags.Kubeconfig
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{
+		CurrentContext: flags.KubeContext,
+	})
+}
+
+// currentContext returns the pinned context, or the current one of the
+// kubeconfig.
+func currentContext(raw clientcmdapi.Config) (string, error) {
+	name := raw.CurrentContext
+	if flags.KubeContext != "" {
+		name = flags.KubeContext
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("no current context found")
+	}
+
+	if _, ok := raw.Contexts[name]; !ok {
+		return "", fmt.Errorf("context %q not found in kubeconfig", name)
+	}
+
+	return name, nil
+}
+
+// WriteSessionKubeconfig writes a kubeconfig holding only the pinned context,
+// with its credentials inlined, so that shells started by inkube keep talking
+// to that cluster whatever `kubectl config use-context` does elsewhere. It is
+// written with mode 0600 to the first of runtimeDirs, the kubeconfigs left
+// behind by sessions that didn't exit cleanly are removed first. The caller
+// removes the file once done with it.
+func WriteSessionKubeconfig() (string, error) {
+	raw, err := kubeConfig().RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	name, err := currentContext(raw)
+	if err != nil {
+		return "", err
+	}
+
+	raw.CurrentContext = name
+	if err := clientcmdapi.MinifyConfig(&raw); err != nil {
+		return "", err
+	}
+
+	if err := clientcmdapi.FlattenConfig(&raw); err != nil {
+		return "", err
+	}
+
+	dirs := runtimeDirs()
+	removeSessionKubeconfigs(append(dirs, flags.GetCacheDir()))
+
+	for _, dir := range dirs {
+		f, err := os.CreateTemp(dir, fmt.Sprintf("inkube-session-%d-*.kubeconfig", os.Getpid()))
+		if err != nil {
+			continue
+		}
+		f.Close()
+
+		if err := os.Chmod(f.Name(), 0o600); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+
+		if err := clientcmd.WriteToFile(raw, f.Name()); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+
+		return f.Name(), nil
+	}
+
+	return "", fmt.Errorf("failed to create a session kubeconfig")
+}
+
+// removeSessionKubeconfigs removes the session kubeconfigs in dirs whose
+// inkube is no longer running, and the ones older versions wrote to the
+// cache.
+func removeSessionKubeconfigs(dirs []string) {
+	for _, dir := range dirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "*session-*.kubeconfig"))
+		for _, f := range files {
+			base := filepath.Base(f)
+			if !strings.HasPrefix(base, "inkube-session-") && !strings.HasPrefix(base, "session-") {
+				continue
+			}
+
+			pid, _, ok := strings.Cut(strings.TrimPrefix(base, "inkube-session-"), "-")
+			if n, err := strconv.Atoi(pid); ok && err == nil && running(n) {
+				continue
+			}
+
+			os.Remove(f)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package kube
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abdheshnayak/inkube/flags"
+)
+
+func TestWriteSessionKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	kc := filepath.Join(dir, "config")
+	if err := os.WriteFile(kc, []byte(`apiVersion: v1
+kind: Config
+current-context: other
+contexts:
+- name: staging
+  context: {cluster: staging, user: admin}
+- name: other
+  context: {cluster: other, user: admin}
+clusters:
+- name: staging
+  cluster: {server: "https://staging:6443"}
+- name: other
+  cluster: {server: "https://other:6443"}
+users:
+- name: admin
+  user: {token: s3cr3t}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldKubeconfig, oldContext, oldCache := flags.Kubeconfig, flags.KubeContext, flags.CacheDir
+	defer func() { flags.Kubeconfig, flags.KubeContext, flags.CacheDir = oldKubeconfig, oldContext, oldCache }()
+	flags.Kubeconfig, flags.KubeContext, flags.CacheDir = kc, "staging", filepath.Join(dir, "cache")
+
+	// written to the cache by an older inkube
+	legacy := filepath.Join(flags.GetCacheDir(), "session-123.kubeconfig")
+	if err := os.WriteFile(legacy, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := WriteSessionKubeconfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	if filepath.Dir(path) != runtimeDirs()[0] {
+		t.Errorf("written to %s, want %s", path, runtimeDirs()[0])
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("got mode %o, want 600", fi.Mode().Perm())
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); !strings.Contains(s, "current-context: staging") || strings.Contains(s, "other") || !strings.Contains(s, "s3cr3t") {
+		t.Errorf("got\n%s\nwant only the staging context, flattened", s)
+	}
+
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("the session kubeconfig left in the cache wasn't removed")
+	}
+}
+
+func TestRemoveSessionKubeconfigs(t *testing.T) {
+	dir := t.TempDir()
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+
+	live := filepath.Join(dir, fmt.Sprintf("inkube-session-%d-1.kubeconfig", os.Getpid()))
+	dead := filepath.Join(dir, fmt.Sprintf("inkube-session-%d-2.kubeconfig", cmd.Process.Pid))
+	legacy := filepath.Join(dir, "session-3.kubeconfig")
+	other := filepath.Join(dir, "my-session-4.kubeconfig")
+	for _, f := range []string{live, dead, legacy, other} {
+		if err := os.WriteFile(f, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeSessionKubeconfigs([]string{dir})
+
+	for f, keep := range map[string]bool{live: true, dead: false, legacy: false, other: true} {
+		_, err := os.Stat(f)
+		if kept := err == nil; kept != keep {
+			t.Errorf("%s: kept %v, want %v", filepath.Base(f), kept, keep)
+		}
+	}
+}
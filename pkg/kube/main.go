@@ -3,15 +3,14 @@ package kube
 import (
 	"context"
 	"fmt"
-	"maps"
 	"net"
 	"os"
-	"path"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/abdheshnayak/inkube/flags"
-	"github.com/abdheshnayak/inkube/pkg/egob"
+	"github.com/abdheshnayak/inkube/pkg/cache"
 	"github.com/abdheshnayak/inkube/pkg/fn"
 	"github.com/abdheshnayak/inkube/pkg/ui/spinner"
 	"github.com/abdheshnayak/inkube/pkg/ui/text"
@@ -20,7 +19,6 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 var (
@@ -42,6 +40,7 @@ func Singleton() *Client {
 
 type Client struct {
 	*kubernetes.Clientset
+	config *rest.Config
 	cancel context.CancelFunc
 }
 
@@ -52,37 +51,38 @@ func (c *Client) Ctx() context.Context {
 }
 
 func NewClient() (*Client, error) {
-	client, err := getClient()
+	config, err := getRestConfig()
 	if err != nil {
 		return nil, err
 	}
-	return &Client{
-		Clientset: client,
-		cancel:    nil,
-	}, nil
+
+	return NewClientForConfig(config)
 }
 
-func getClient() (*kubernetes.Clientset, error) {
-	config, err := getRestConfig()
+// NewClientForConfig returns a client of the cluster config points at.
+func NewClientForConfig(config *rest.Config) (*Client, error) {
+	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	return kubernetes.NewForConfig(config)
+	return &Client{
+		Clientset: client,
+		config:    config,
+		cancel:    nil,
+	}, nil
 }
 
 func getRestConfig() (*rest.Config, error) {
-	// 1. Use in-cluster config if running inside a pod
-	if config, err := rest.InClusterConfig(); err == nil {
-		return config, nil
+	// 1. Use in-cluster config if running inside a pod, unless a cluster is pinned
+	if !IsPinned() {
+		if config, err := rest.InClusterConfig(); err == nil {
+			return config, nil
+		}
 	}
 
-	// 2. Use KUBECONFIG env var or default path
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules() // supports $KUBECONFIG and ~/.kube/config
-	configOverrides := &clientcmd.ConfigOverrides{}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-
-	config, err := kubeConfig.ClientConfig()
+	// 2. Use the pinned kubeconfig and context, or KUBECONFIG env var and default path
+	config, err := kubeConfig().ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
 	}
@@ -90,109 +90,148 @@ func getRestConfig() (*rest.Config, error) {
 	return config, nil
 }
 
-func (c *Client) GetEnvs(namespace, name, contname string, refetch bool) (map[string]string, error) {
+// EnvOptions controls how GetEnvs reads the env of a container.
+type EnvOptions struct {
+	// Pod is the pod downward API values, and the runtime env with
+	// EnvSourceExec, are read from.
+	Pod PodIdentity
+
+	// Source defaults to EnvSourceSpec.
+	Source EnvSource
+
+	// Refetch skips the cache.
+	Refetch bool
+
+	// NoStore leaves the cache as it is, the env read is only returned.
+	NoStore bool
+}
+
+// GetEnvs reads the env of a container of a workload, or takes it from the
+// cache when it is up to date. Values read from Secrets are registered with
+// fn.AddSecrets.
+func (c *Client) GetEnvs(namespace string, workload WorkloadRef, contname string, opts EnvOptions) (*Envs, error) {
 	defer spinner.Client.UpdateMessage("Getting environment variables")()
-	cacheDir := flags.GetCacheDir()
-	fileNamePath := path.Join(cacheDir, fmt.Sprintf("%s-%s-%s.secret.cache", namespace, name, contname))
-
-	if !refetch {
-		if evs, err := func() (map[string]string, error) {
-			b, err := os.ReadFile(fileNamePath)
-			if err != nil {
-				return nil, err
-			}
-			resp := make(map[string]string)
-			if err := egob.Unmarshal(b, &resp); err != nil {
-				return nil, err
-			}
-			return resp, nil
-		}(); err == nil {
-			fn.Log(text.Blue("[#] using cached env vars"))
-			return evs, nil
+
+	entry := c.envCacheEntry(namespace, workload, contname, opts.Source)
+	source := entry.Source
+	cacheName := envCacheName(entry)
+
+	if !opts.Refetch {
+		if envs, ok := c.cachedEnvs(cacheName); ok {
+			fn.AddSecrets(envs.Secrets()...)
+			return envs, nil
 		}
 	}
 
-	envs := make(map[string]string)
-	// Get the Deployment
-	deploy, err := c.AppsV1().Deployments(namespace).Get(c.Ctx(), name, v1.GetOptions{})
+	w, err := c.GetWorkload(c.Ctx(), namespace, workload)
 	if err != nil {
 		return nil, err
 	}
 
-	var container *corev1.Container
-	for _, cont := range deploy.Spec.Template.Spec.Containers {
-		if cont.Name == contname {
-			container = &cont
-			break
-		}
+	container, err := w.Container(contname)
+	if err != nil {
+		return nil, err
 	}
-	if container == nil {
-		return nil, fmt.Errorf("container %s not found", contname)
+
+	ctx := c.Ctx()
+	// the values of a bare pod come from the pod itself
+	if w.Kind == KindPod && opts.Pod.Name == "" {
+		opts.Pod.Name = w.Name
 	}
 
-	// Handle Env
-	for _, env := range container.Env {
-		if env.Value != "" {
-			envs[env.Name] = env.Value
-		} else if env.ValueFrom != nil {
-			switch {
-			case env.ValueFrom.ConfigMapKeyRef != nil:
-				cm, err := c.CoreV1().ConfigMaps(namespace).Get(c.Ctx(), env.ValueFrom.ConfigMapKeyRef.Name, v1.GetOptions{})
-				if err != nil {
-					return nil, err
-				}
-				val := cm.Data[env.ValueFrom.ConfigMapKeyRef.Key]
-				envs[env.Name] = val
-
-			case env.ValueFrom.SecretKeyRef != nil:
-				secret, err := c.CoreV1().Secrets(namespace).Get(c.Ctx(), env.ValueFrom.SecretKeyRef.Name, v1.GetOptions{})
-				if err != nil {
-					return nil, err
-				}
-				val := string(secret.Data[env.ValueFrom.SecretKeyRef.Key])
-				envs[env.Name] = val
-
-			case env.ValueFrom.FieldRef != nil:
-				envs[env.Name] = fmt.Sprintf("fieldRef: %s", env.ValueFrom.FieldRef.FieldPath)
-
-			case env.ValueFrom.ResourceFieldRef != nil:
-				envs[env.Name] = fmt.Sprintf("resourceFieldRef: %s", env.ValueFrom.ResourceFieldRef.Resource)
-			}
-		}
+	d := newDownwardAPI(ctx, c.Clientset, namespace, w.Spec, w.Selector, opts.Pod)
+
+	objs := newObjects(ctx, c.Clientset, namespace)
+	envs, warnings, err := resolveEnv(objs, container, d)
+	if err != nil {
+		return nil, err
+	}
+	fn.AddSecrets(envs.Secrets()...)
+
+	for _, w := range warnings {
+		fn.Log(text.Yellow("[!] " + w))
 	}
 
-	// Handle EnvFrom
-	for _, envFrom := range container.EnvFrom {
-		if envFrom.ConfigMapRef != nil {
-			cm, err := c.CoreV1().ConfigMaps(namespace).Get(c.Ctx(), envFrom.ConfigMapRef.Name, v1.GetOptions{})
-			if err != nil {
-				return nil, err
-			}
-			maps.Copy(envs, cm.Data)
+	if source == EnvSourceExec {
+		if !isRunning(contname, container) {
+			return nil, fmt.Errorf("%s has exited in the pods, its env can't be read with source: exec", contname)
+		}
+
+		pod, err := d.getPod()
+		if err != nil {
+			return nil, err
+		}
+
+		runtime, err := c.execEnv(ctx, pod, container.Name)
+		if err != nil {
+			return nil, err
 		}
-		if envFrom.SecretRef != nil {
-			secret, err := c.CoreV1().Secrets(namespace).Get(c.Ctx(), envFrom.SecretRef.Name, v1.GetOptions{})
-			if err != nil {
-				return nil, err
-			}
-			for k, v := range secret.Data {
-				envs[k] = string(v)
-			}
+
+		if only := mergeRuntimeEnv(envs, runtime); len(only) > 0 {
+			fn.AddSecrets(envs.Secrets()...)
+			fn.Log(text.Blue(fmt.Sprintf("[#] only set at runtime in %s: %s", pod.Name, strings.Join(only, ", "))))
 		}
 	}
 
-	b, err := egob.Marshal(envs)
-	if err != nil {
-		fn.Log(text.Yellow("[!] failed to marshal env vars"))
-		return envs, err
+	entry.Versions = objs.versions
+	entry.Versions[w.String()] = w.ResourceVersion
+	if d.pod != nil {
+		entry.Versions["pod/"+d.pod.Name] = d.pod.ResourceVersion
+	}
+	entry.Time = time.Now()
+	entry.Envs = envs.Values
+	entry.Provenance = envs.Provenance
+
+	if opts.NoStore {
+		return envs, nil
 	}
 
-	if err := os.WriteFile(fileNamePath, b, 0o644); err != nil {
-		fn.Log(text.Yellow("[!] failed to env vars to cache"))
+	if err := cache.Write(cacheName, entry); err != nil {
+		fn.Log(text.Yellow("[!] failed to write env vars to cache: " + err.Error()))
 	}
 	return envs, nil
 }
 
+// cachedEnvs returns the cached env if the objects it was read from are
+// unchanged. When that can't be checked, e.g. offline, it is used as long as
+// it is younger than flags.CacheTTL.
+func (c *Client) cachedEnvs(name string) (*Envs, bool) {
+	var e EnvCacheEntry
+	if err := cache.Read(name, &e); err != nil {
+		return nil, false
+	}
+
+	// written by an older inkube, it can't tell secrets apart
+	if e.Provenance == nil {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mc, err := c.metadataClient()
+	fresh := false
+	if err == nil {
+		fresh, err = upToDate(ctx, mc, &e)
+	}
+
+	if err != nil {
+		if time.Since(e.Time) < flags.CacheTTL {
+			fn.Log(text.Yellow(fmt.Sprintf("[!] couldn't check whether the cached env vars are up to date, using them as of %s: %v", e.Time.Format(time.DateTime), err)))
+			return e.envs(), true
+		}
+		return nil, false
+	}
+
+	if !fresh {
+		fn.Log(text.Blue("[#] cached env vars are outdated, refetching"))
+		return nil, false
+	}
+
+	fn.Log(text.Blue("[#] using cached env vars"))
+	return e.envs(), true
+}
+
 // func (c *Client) GetEnvs(namespace string, name string, contname string) (map[string]string, error) {
 // 	envs := make(map[string]string)
 // 	pod, err := c.AppsV1().Deployments(namespace).Get(c.Ctx(), name, v1.GetOptions{})
@@ -228,22 +267,17 @@ func IsConnected() bool {
 }
 
 func (c *Client) GetClusterName() (string, error) {
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(),
-		&clientcmd.ConfigOverrides{},
-	)
-
-	rawCfg, err := kubeConfig.RawConfig()
+	rawCfg, err := kubeConfig().RawConfig()
 	if err != nil {
 		return "", err
 	}
 
-	clusterName := rawCfg.CurrentContext
-	if clusterName == "" {
-		return "", fmt.Errorf("no current context found")
+	name, err := currentContext(rawCfg)
+	if err != nil {
+		return "", err
 	}
 
-	return rawCfg.Contexts[rawCfg.CurrentContext].Cluster, nil
+	return rawCfg.Contexts[name].Cluster, nil
 }
 
 func (c *Client) EnsureNamespace(namespace string) error {
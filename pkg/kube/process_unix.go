@@ -0,0 +1,14 @@
+//go:build !windows
+
+package kube
+
+import (
+	"errors"
+	"syscall"
+)
+
+// running tells whether the process pid is still running.
+func running(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
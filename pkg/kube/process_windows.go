@@ -0,0 +1,16 @@
+//go:build windows
+
+package kube
+
+import "os"
+
+// running tells whether the process pid is still running, FindProcess fails
+// on windows once it has exited.
+func running(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
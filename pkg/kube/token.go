@@ -0,0 +1,221 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/spinner"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ServiceAccountDir is where pods find the token of their service account,
+// and where rest.InClusterConfig reads it from.
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// defaultTokenExpiration is the lifetime of tokens, the kubelet's default.
+const defaultTokenExpiration = time.Hour
+
+// tokenFile is a file holding a service account token, rewritten with a new
+// token before the current one expires.
+type tokenFile struct {
+	path       string
+	audiences  []string
+	expiration time.Duration
+	mode       os.FileMode
+}
+
+// tokens mints the tokens of the service account of a pod.
+type tokens struct {
+	client         kubernetes.Interface
+	namespace      string
+	serviceAccount string
+}
+
+func newTokens(c kubernetes.Interface, namespace string, spec *corev1.PodSpec) *tokens {
+	sa := spec.ServiceAccountName
+	if sa == "" {
+		sa = "default"
+	}
+
+	return &tokens{client: c, namespace: namespace, serviceAccount: sa}
+}
+
+func (t *tokens) mint(ctx context.Context, f tokenFile) (*authv1.TokenRequestStatus, error) {
+	expiration := f.expiration
+	if expiration == 0 {
+		expiration = defaultTokenExpiration
+	}
+	seconds := int64(expiration.Seconds())
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tr, err := t.client.CoreV1().ServiceAccounts(t.namespace).CreateToken(ctx, t.serviceAccount, &authv1.TokenRequest{
+		Spec: authv1.TokenRequestSpec{
+			Audiences:         f.audiences,
+			ExpirationSeconds: &seconds,
+		},
+	}, v1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request a token for service account %s/%s: %w", t.namespace, t.serviceAccount, err)
+	}
+
+	return &tr.Status, nil
+}
+
+// write mints a token into f, and keeps it fresh until inkube exits.
+func (t *tokens) write(f tokenFile) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	fn.OnCleanup(cancel)
+
+	status, err := t.mint(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+
+	if err := fn.WriteFileAtomic(f.path, []byte(status.Token), f.mode); err != nil {
+		return err
+	}
+
+	go t.refresh(ctx, f, status.ExpirationTimestamp.Time)
+	return nil
+}
+
+// refresh rewrites f when 80% of the lifetime of its token has passed, like
+// the kubelet does, and retries every minute on failure.
+func (t *tokens) refresh(ctx context.Context, f tokenFile, expires time.Time) {
+	for {
+		wait := time.Until(expires) * 4 / 5
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		status, err := t.mint(ctx, f)
+		if err == nil {
+			err = fn.WriteFileAtomic(f.path, []byte(status.Token), f.mode)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			fn.Log(text.Yellow(fmt.Sprintf("[!] failed to refresh the token %s: %v", f.path, err)))
+			expires = time.Now().Add(time.Minute * 5 / 4)
+			continue
+		}
+
+		expires = status.ExpirationTimestamp.Time
+	}
+}
+
+// MountServiceAccount writes a token of the service account of the
+// workload, the CA of the cluster and the namespace under root, at
+// ServiceAccountDir, and returns the env vars that point in-cluster clients
+// to the API server. The token is refreshed until inkube exits.
+func (c *Client) MountServiceAccount(namespace string, workload WorkloadRef, root string, expiration time.Duration) (map[string]string, error) {
+	defer spinner.Client.UpdateMessage("requesting a service account token")()
+
+	ctx := c.Ctx()
+	w, err := c.GetWorkload(ctx, namespace, workload)
+	if err != nil {
+		return nil, err
+	}
+
+	if am := w.Spec.AutomountServiceAccountToken; am != nil && !*am {
+		fn.Log(text.Yellow(fmt.Sprintf("[!] %s doesn't mount its service account token, minting one anyway", workload)))
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(ServiceAccountDir))
+
+	t := newTokens(c.Clientset, namespace, w.Spec)
+	if err := t.write(tokenFile{path: filepath.Join(dir, "token"), expiration: expiration, mode: 0o600}); err != nil {
+		return nil, err
+	}
+	fn.Log(text.Blue(fmt.Sprintf("[#] using the token of service account %s", t.serviceAccount)))
+
+	ca, err := c.caData(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn.WriteFileAtomic(filepath.Join(dir, "ca.crt"), ca, 0o644); err != nil {
+		return nil, err
+	}
+
+	if err := fn.WriteFileAtomic(filepath.Join(dir, "namespace"), []byte(namespace), 0o644); err != nil {
+		return nil, err
+	}
+
+	host, port, err := apiServer(c.config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"KUBERNETES_SERVICE_HOST":       host,
+		"KUBERNETES_SERVICE_PORT":       port,
+		"KUBERNETES_SERVICE_PORT_HTTPS": port,
+	}, nil
+}
+
+// caData returns the CA of the API server inkube talks to, which in-cluster
+// clients will talk to as well, or the one pods get.
+func (c *Client) caData(ctx context.Context, namespace string) ([]byte, error) {
+	if len(c.config.CAData) > 0 {
+		return c.config.CAData, nil
+	}
+
+	if c.config.CAFile != "" {
+		return os.ReadFile(c.config.CAFile)
+	}
+
+	cm, err := c.CoreV1().ConfigMaps(namespace).Get(ctx, "kube-root-ca.crt", v1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA of the cluster: %w", err)
+	}
+
+	return []byte(cm.Data["ca.crt"]), nil
+}
+
+// apiServer splits the address of the API server, in-cluster clients
+// rebuild it from KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT.
+func apiServer(address string) (host, port string, err error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		// host or host:port without a scheme
+		u, err = url.Parse("https://" + address)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid API server address %s: %w", address, err)
+		}
+	}
+
+	if u.Path != "" && u.Path != "/" {
+		fn.Log(text.Yellow(fmt.Sprintf("[!] in-cluster clients ignore the path of the API server address %s", address)))
+	}
+
+	port = u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	return u.Hostname(), port, nil
+}
@@ -0,0 +1,103 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTokens(t *testing.T) {
+	defer fn.Cleanup()
+
+	var requests []*authv1.TokenRequest
+	c := fake.NewClientset()
+	c.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create := action.(k8stesting.CreateActionImpl)
+		if create.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+
+		tr := create.GetObject().(*authv1.TokenRequest)
+		requests = append(requests, tr)
+
+		token := "token-of-" + create.Name
+		if len(tr.Spec.Audiences) > 0 {
+			token += "-for-" + tr.Spec.Audiences[0]
+		}
+
+		tr.Status = authv1.TokenRequestStatus{
+			Token:               token,
+			ExpirationTimestamp: v1.NewTime(time.Now().Add(time.Duration(*tr.Spec.ExpirationSeconds) * time.Second)),
+		}
+		return true, tr, nil
+	})
+
+	dir := t.TempDir()
+	tokens := newTokens(c, testNamespace, &corev1.PodSpec{ServiceAccountName: "api-sa"})
+
+	if err := tokens.write(tokenFile{path: filepath.Join(dir, "token"), mode: 0o600}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tokens.write(tokenFile{path: filepath.Join(dir, "vault"), audiences: []string{"vault"}, expiration: 10 * time.Minute, mode: 0o644}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"token": "token-of-api-sa", "vault": "token-of-api-sa-for-vault"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", name, b, want)
+		}
+	}
+
+	if got := *requests[0].Spec.ExpirationSeconds; got != 3600 {
+		t.Errorf("default expiration: got %ds, want 3600s", got)
+	}
+
+	if got := *requests[1].Spec.ExpirationSeconds; got != 600 {
+		t.Errorf("expiration: got %ds, want 600s", got)
+	}
+
+	if fi, _ := os.Stat(filepath.Join(dir, "token")); fi.Mode().Perm() != 0o600 {
+		t.Errorf("got mode %o, want 600", fi.Mode().Perm())
+	}
+}
+
+func TestAPIServer(t *testing.T) {
+	tests := []struct {
+		address string
+		host    string
+		port    string
+	}{
+		{address: "https://10.0.0.1:6443", host: "10.0.0.1", port: "6443"},
+		{address: "https://api.example.com", host: "api.example.com", port: "443"},
+		{address: "http://localhost", host: "localhost", port: "80"},
+		{address: "10.0.0.1:6443", host: "10.0.0.1", port: "6443"},
+		{address: "https://[fd00::1]:6443", host: "fd00::1", port: "6443"},
+	}
+
+	for _, tt := range tests {
+		host, port, err := apiServer(tt.address)
+		if err != nil {
+			t.Errorf("%s: %v", tt.address, err)
+			continue
+		}
+
+		if host != tt.host || port != tt.port {
+			t.Errorf("%s: got %s %s, want %s %s", tt.address, host, port, tt.host, tt.port)
+		}
+	}
+}
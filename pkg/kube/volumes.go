@@ -0,0 +1,360 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"github.com/abdheshnayak/inkube/pkg/ui/spinner"
+	"github.com/abdheshnayak/inkube/pkg/ui/text"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Mount is a volume mount of the container mirrored to a local directory.
+type Mount struct {
+	// Volume is the name of the volume.
+	Volume string
+
+	// Path is the mount path in the container.
+	Path string
+
+	// Local is where the files of the mount are.
+	Local string
+}
+
+// MountOptions controls how MirrorVolumes reads and writes volumes.
+type MountOptions struct {
+	// Root is the directory mount paths are mirrored under, see MountRoot.
+	Root string
+
+	// Pod is the pod downwardAPI volumes are read from.
+	Pod PodIdentity
+
+	// Env expands the subPathExpr of mounts.
+	Env map[string]string
+}
+
+// runtimeDirs returns the directories files holding secrets are written
+// to, a tmpfs first when there is one so that secrets never hit the disk.
+func runtimeDirs() []string {
+	var dirs []string
+	for _, dir := range []string{"/dev/shm", os.Getenv("XDG_RUNTIME_DIR"), os.TempDir()} {
+		if dir == "" {
+			continue
+		}
+
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			continue
+		}
+
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// MountRoot creates a directory to mirror volumes to, in the first of
+// runtimeDirs it can. The caller removes it.
+func MountRoot() (string, error) {
+	for _, dir := range runtimeDirs() {
+		if root, err := os.MkdirTemp(dir, "inkube-"); err == nil {
+			return root, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to create a directory to mirror volumes to")
+}
+
+// MirrorVolumes writes the files of the ConfigMap, Secret, projected and
+// downwardAPI volumes mounted in container under opts.Root, each at its
+// mount path. Other kinds of volumes are skipped.
+func (c *Client) MirrorVolumes(namespace string, workload WorkloadRef, container string, opts MountOptions) ([]Mount, error) {
+	defer spinner.Client.UpdateMessage("mirroring volumes")()
+
+	ctx := c.Ctx()
+	w, err := c.GetWorkload(ctx, namespace, workload)
+	if err != nil {
+		return nil, err
+	}
+
+	cont, err := w.Container(container)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Kind == KindPod && opts.Pod.Name == "" {
+		opts.Pod.Name = w.Name
+	}
+
+	d := newDownwardAPI(ctx, c.Clientset, namespace, w.Spec, w.Selector, opts.Pod)
+
+	mounts, warnings, err := mirrorVolumes(ctx, c.Clientset, namespace, cont, d, opts)
+	for _, w := range warnings {
+		fn.Log(text.Yellow("[!] " + w))
+	}
+
+	return mounts, err
+}
+
+type volumeFile struct {
+	data []byte
+	mode os.FileMode
+
+	// token is set for service account tokens, minted when written
+	token *tokenFile
+}
+
+func mirrorVolumes(ctx context.Context, c kubernetes.Interface, namespace string, container *corev1.Container, d *downwardAPI, opts MountOptions) (mounts []Mount, warnings []string, err error) {
+	m := &mirror{
+		objs:      newObjects(ctx, c, namespace),
+		d:         d,
+		container: container,
+		tokens:    newTokens(c, namespace, d.spec),
+		volumes:   map[string]map[string]volumeFile{},
+	}
+
+	for _, vm := range container.VolumeMounts {
+		files, err := m.volume(vm.Name)
+		if err != nil {
+			return nil, m.warnings, fmt.Errorf("failed to read volume %s: %w", vm.Name, err)
+		}
+
+		if files == nil {
+			continue
+		}
+
+		sub := vm.SubPath
+		if vm.SubPathExpr != "" {
+			sub = expandEnv(vm.SubPathExpr, opts.Env)
+		}
+
+		local := filepath.Join(opts.Root, filepath.FromSlash(vm.MountPath))
+		if sub == "" {
+			// volumes can be empty, e.g. with a missing optional ConfigMap
+			if err := os.MkdirAll(local, 0o755); err != nil {
+				return nil, m.warnings, err
+			}
+		}
+
+		for name, f := range files {
+			if sub != "" {
+				if name == sub {
+					name = ""
+				} else if rest, ok := strings.CutPrefix(name, sub+"/"); ok {
+					name = rest
+				} else {
+					continue
+				}
+			}
+
+			if err := m.write(opts.Root, filepath.Join(local, filepath.FromSlash(name)), f); err != nil {
+				return nil, m.warnings, err
+			}
+		}
+
+		mounts = append(mounts, Mount{Volume: vm.Name, Path: vm.MountPath, Local: local})
+	}
+
+	return mounts, m.warnings, nil
+}
+
+func (m *mirror) write(root, path string, f volumeFile) error {
+	// item paths can't leave the volume, checked anyway as the files are
+	// written outside of a container
+	if !strings.HasPrefix(filepath.Clean(path), filepath.Clean(root)+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside of %s", path, root)
+	}
+
+	if f.token != nil {
+		t := *f.token
+		t.path, t.mode = path, f.mode
+		return m.tokens.write(t)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// the file may be there read-only from another mount
+	os.Remove(path)
+	if err := os.WriteFile(path, f.data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Chmod(path, f.mode)
+}
+
+// mirror reads the files of the volumes of a pod.
+type mirror struct {
+	objs      *objects
+	d         *downwardAPI
+	tokens    *tokens
+	container *corev1.Container
+
+	// files of the volumes by name, nil for volumes that aren't mirrored
+	volumes  map[string]map[string]volumeFile
+	warnings []string
+}
+
+func (m *mirror) volume(name string) (map[string]volumeFile, error) {
+	if files, ok := m.volumes[name]; ok {
+		return files, nil
+	}
+
+	i := slices.IndexFunc(m.d.spec.Volumes, func(v corev1.Volume) bool {
+		return v.Name == name
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("volume not found in the pod spec")
+	}
+	v := m.d.spec.Volumes[i]
+
+	files := map[string]volumeFile{}
+	var err error
+
+	switch {
+	case v.ConfigMap != nil:
+		err = m.configMap(files, v.ConfigMap.Name, v.ConfigMap.Items, v.ConfigMap.Optional, fileMode(v.ConfigMap.DefaultMode, corev1.ConfigMapVolumeSourceDefaultMode))
+
+	case v.Secret != nil:
+		err = m.secret(files, v.Secret.SecretName, v.Secret.Items, v.Secret.Optional, fileMode(v.Secret.DefaultMode, corev1.SecretVolumeSourceDefaultMode))
+
+	case v.DownwardAPI != nil:
+		err = m.downwardAPI(files, v.DownwardAPI.Items, fileMode(v.DownwardAPI.DefaultMode, corev1.DownwardAPIVolumeSourceDefaultMode))
+
+	case v.Projected != nil:
+		mode := fileMode(v.Projected.DefaultMode, corev1.ProjectedVolumeSourceDefaultMode)
+		for _, src := range v.Projected.Sources {
+			switch {
+			case src.ConfigMap != nil:
+				err = m.configMap(files, src.ConfigMap.Name, src.ConfigMap.Items, src.ConfigMap.Optional, mode)
+			case src.Secret != nil:
+				err = m.secret(files, src.Secret.Name, src.Secret.Items, src.Secret.Optional, mode)
+			case src.DownwardAPI != nil:
+				err = m.downwardAPI(files, src.DownwardAPI.Items, mode)
+			case src.ServiceAccountToken != nil:
+				files[src.ServiceAccountToken.Path] = volumeFile{mode: mode, token: serviceAccountToken(src.ServiceAccountToken)}
+			case src.ClusterTrustBundle != nil:
+				m.warnings = append(m.warnings, fmt.Sprintf("cluster trust bundle %s of volume %s is not mirrored", src.ClusterTrustBundle.Path, name))
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		files = nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.volumes[name] = files
+	return files, nil
+}
+
+func (m *mirror) configMap(files map[string]volumeFile, name string, items []corev1.KeyToPath, optional *bool, mode os.FileMode) error {
+	cm, err := m.objs.configMap(name)
+	if err != nil {
+		if errors.IsNotFound(err) && isOptional(optional) {
+			return nil
+		}
+		return err
+	}
+
+	data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for k, v := range cm.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range cm.BinaryData {
+		data[k] = v
+	}
+
+	return addKeys(files, "configmap "+name, data, items, optional, mode)
+}
+
+func (m *mirror) secret(files map[string]volumeFile, name string, items []corev1.KeyToPath, optional *bool, mode os.FileMode) error {
+	s, err := m.objs.secret(name)
+	if err != nil {
+		if errors.IsNotFound(err) && isOptional(optional) {
+			return nil
+		}
+		return err
+	}
+
+	return addKeys(files, "secret "+name, s.Data, items, optional, mode)
+}
+
+// addKeys adds the keys of a ConfigMap or Secret as files, every key named
+// after itself, or only items at their path.
+func addKeys(files map[string]volumeFile, source string, data map[string][]byte, items []corev1.KeyToPath, optional *bool, mode os.FileMode) error {
+	if len(items) == 0 {
+		for k, v := range data {
+			files[k] = volumeFile{data: v, mode: mode}
+		}
+		return nil
+	}
+
+	for _, item := range items {
+		v, ok := data[item.Key]
+		if !ok {
+			if isOptional(optional) {
+				continue
+			}
+			return fmt.Errorf("couldn't find key %s in %s", item.Key, source)
+		}
+
+		files[item.Path] = volumeFile{data: v, mode: fileMode(item.Mode, int32(mode))}
+	}
+
+	return nil
+}
+
+func (m *mirror) downwardAPI(files map[string]volumeFile, items []corev1.DownwardAPIVolumeFile, mode os.FileMode) error {
+	for _, item := range items {
+		var v string
+		var err error
+
+		switch {
+		case item.FieldRef != nil:
+			v, err = m.d.field(item.FieldRef.FieldPath)
+		case item.ResourceFieldRef != nil:
+			v, err = m.d.resource(m.container, item.ResourceFieldRef)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", item.Path, err)
+		}
+
+		files[item.Path] = volumeFile{data: []byte(v), mode: fileMode(item.Mode, int32(mode))}
+	}
+
+	return nil
+}
+
+func serviceAccountToken(p *corev1.ServiceAccountTokenProjection) *tokenFile {
+	t := &tokenFile{}
+	if p.Audience != "" {
+		t.audiences = []string{p.Audience}
+	}
+
+	if p.ExpirationSeconds != nil {
+		t.expiration = time.Duration(*p.ExpirationSeconds) * time.Second
+	}
+
+	return t
+}
+
+func fileMode(mode *int32, def int32) os.FileMode {
+	if mode != nil {
+		return os.FileMode(*mode)
+	}
+	return os.FileMode(def)
+}
@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMirrorVolumes(t *testing.T) {
+	mode := func(m int32) *int32 { return &m }
+
+	c := fake.NewClientset(
+		configMap("app", map[string]string{"app.yaml": "port: 80", "other.yaml": "x: 1"}),
+		secret("tls", map[string]string{"tls.crt": "cert", "tls.key": "key"}),
+	)
+
+	spec := &corev1.PodSpec{
+		Volumes: []corev1.Volume{
+			{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "app"},
+				Items:                []corev1.KeyToPath{{Key: "app.yaml", Path: "conf/app.yaml", Mode: mode(0o600)}},
+			}}},
+			{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  "tls",
+				DefaultMode: mode(0o400),
+			}}},
+			{Name: "optional", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "missing"},
+				Optional:             optional(),
+			}}},
+			{Name: "projected", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "app"}}},
+					{DownwardAPI: &corev1.DownwardAPIProjection{Items: []corev1.DownwardAPIVolumeFile{
+						{Path: "name", FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+					}}},
+				},
+			}}},
+			{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+	}
+
+	container := &corev1.Container{
+		Name: "api",
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "config", MountPath: "/etc/config"},
+			{Name: "tls", MountPath: "/etc/tls"},
+			{Name: "tls", MountPath: "/etc/ssl/server.crt", SubPath: "tls.crt"},
+			{Name: "optional", MountPath: "/etc/optional"},
+			{Name: "projected", MountPath: "/etc/podinfo"},
+			{Name: "projected", MountPath: "/etc/by-env", SubPathExpr: "$(FILE)"},
+			{Name: "scratch", MountPath: "/tmp"},
+		},
+	}
+
+	root := t.TempDir()
+	d := newDownwardAPI(context.Background(), c, testNamespace, spec, nil, PodIdentity{Fields: map[string]string{"metadata.name": "api-1"}})
+
+	mounts, _, err := mirrorVolumes(context.Background(), c, testNamespace, container, d, MountOptions{
+		Root: root,
+		Env:  map[string]string{"FILE": "other.yaml"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mounts) != 6 {
+		t.Errorf("got %d mounts, want 6: %v", len(mounts), mounts)
+	}
+
+	files := []struct {
+		path string
+		data string
+		mode os.FileMode
+	}{
+		{path: "etc/config/conf/app.yaml", data: "port: 80", mode: 0o600},
+		{path: "etc/tls/tls.crt", data: "cert", mode: 0o400},
+		{path: "etc/tls/tls.key", data: "key", mode: 0o400},
+		{path: "etc/ssl/server.crt", data: "cert", mode: 0o400},
+		{path: "etc/podinfo/app.yaml", data: "port: 80", mode: 0o644},
+		{path: "etc/podinfo/name", data: "api-1", mode: 0o644},
+		{path: "etc/by-env", data: "x: 1", mode: 0o644},
+	}
+
+	for _, f := range files {
+		p := filepath.Join(root, f.path)
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Errorf("%s: %v", f.path, err)
+			continue
+		}
+
+		if string(b) != f.data {
+			t.Errorf("%s: got %q, want %q", f.path, b, f.data)
+		}
+
+		if fi, _ := os.Stat(p); fi.Mode().Perm() != f.mode {
+			t.Errorf("%s: got mode %o, want %o", f.path, fi.Mode().Perm(), f.mode)
+		}
+	}
+
+	for _, p := range []string{"etc/config/other.yaml", "tmp"} {
+		if _, err := os.Stat(filepath.Join(root, p)); err == nil {
+			t.Errorf("%s should not be mirrored", p)
+		}
+	}
+
+	if fi, err := os.Stat(filepath.Join(root, "etc/optional")); err != nil || !fi.IsDir() {
+		t.Errorf("the mount of a missing optional ConfigMap should be an empty directory")
+	}
+}
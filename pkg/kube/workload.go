@@ -0,0 +1,292 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WorkloadKind is a kind of workload inkube can target, named the way
+// kubectl, kubevpn and telepresence name it.
+type WorkloadKind string
+
+const (
+	KindDeployment  WorkloadKind = "deployment"
+	KindStatefulSet WorkloadKind = "statefulset"
+	KindDaemonSet   WorkloadKind = "daemonset"
+	KindReplicaSet  WorkloadKind = "replicaset"
+	KindJob         WorkloadKind = "job"
+	KindCronJob     WorkloadKind = "cronjob"
+	KindPod         WorkloadKind = "pod"
+)
+
+// WorkloadKinds are the kinds in the order pickers list them.
+var WorkloadKinds = []WorkloadKind{KindDeployment, KindStatefulSet, KindDaemonSet, KindReplicaSet, KindJob, KindCronJob, KindPod}
+
+// WorkloadRef points at a workload of a namespace. An empty kind is a
+// deployment.
+type WorkloadRef struct {
+	Kind WorkloadKind
+	Name string
+}
+
+// String returns the ref as kind/name, e.g. statefulset/db.
+func (r WorkloadRef) String() string {
+	return fmt.Sprintf("%s/%s", r.kind(), r.Name)
+}
+
+func (r WorkloadRef) kind() WorkloadKind {
+	if r.Kind == "" {
+		return KindDeployment
+	}
+	return r.Kind
+}
+
+// Workload is a workload along with what is needed to find and build its
+// pods.
+type Workload struct {
+	WorkloadRef
+
+	// Spec is the pod template spec, or the spec of the pod itself.
+	Spec *corev1.PodSpec
+
+	// Selector selects the pods of the workload, nil for pods and for cron
+	// jobs that haven't run yet.
+	Selector *v1.LabelSelector
+
+	ResourceVersion string
+}
+
+// InitPrefix names an init container, like init:migrate. Init containers,
+// native sidecars included, are only read when named this way.
+const InitPrefix = "init:"
+
+// Container returns the container called name, an init container when name
+// starts with InitPrefix.
+func (w *Workload) Container(name string) (*corev1.Container, error) {
+	list, n := w.Spec.Containers, name
+	if s, ok := strings.CutPrefix(name, InitPrefix); ok {
+		list, n = w.Spec.InitContainers, s
+	}
+
+	i := slices.IndexFunc(list, func(c corev1.Container) bool {
+		return c.Name == n
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("container %s not found in %s", name, w)
+	}
+
+	return &list[i], nil
+}
+
+// ContainerNames lists the containers of the workload the way Container
+// takes them, the init containers last.
+func (w *Workload) ContainerNames() []string {
+	names := make([]string, 0, len(w.Spec.Containers)+len(w.Spec.InitContainers))
+	for _, c := range w.Spec.Containers {
+		names = append(names, c.Name)
+	}
+	for _, c := range w.Spec.InitContainers {
+		names = append(names, InitPrefix+c.Name)
+	}
+	return names
+}
+
+// isRunning tells whether the container keeps running in the pods, init
+// containers only do when they are native sidecars.
+func isRunning(name string, c *corev1.Container) bool {
+	if !strings.HasPrefix(name, InitPrefix) {
+		return true
+	}
+	return c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+// GetWorkload fetches the workload ref points at.
+func (c *Client) GetWorkload(ctx context.Context, namespace string, ref WorkloadRef) (*Workload, error) {
+	return getWorkload(ctx, c.Clientset, namespace, ref)
+}
+
+func getWorkload(ctx context.Context, c kubernetes.Interface, namespace string, ref WorkloadRef) (*Workload, error) {
+	w := &Workload{WorkloadRef: WorkloadRef{Kind: ref.kind(), Name: ref.Name}}
+	opts := v1.GetOptions{}
+
+	switch w.Kind {
+	case KindDeployment:
+		o, err := c.AppsV1().Deployments(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec, w.Selector, w.ResourceVersion = &o.Spec.Template.Spec, o.Spec.Selector, o.ResourceVersion
+
+	case KindStatefulSet:
+		o, err := c.AppsV1().StatefulSets(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec, w.Selector, w.ResourceVersion = &o.Spec.Template.Spec, o.Spec.Selector, o.ResourceVersion
+
+	case KindDaemonSet:
+		o, err := c.AppsV1().DaemonSets(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec, w.Selector, w.ResourceVersion = &o.Spec.Template.Spec, o.Spec.Selector, o.ResourceVersion
+
+	case KindReplicaSet:
+		o, err := c.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec, w.Selector, w.ResourceVersion = &o.Spec.Template.Spec, o.Spec.Selector, o.ResourceVersion
+
+	case KindJob:
+		o, err := c.BatchV1().Jobs(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec, w.Selector, w.ResourceVersion = &o.Spec.Template.Spec, o.Spec.Selector, o.ResourceVersion
+
+	case KindCronJob:
+		o, err := c.BatchV1().CronJobs(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec, w.ResourceVersion = &o.Spec.JobTemplate.Spec.Template.Spec, o.ResourceVersion
+
+		// the pods belong to the jobs of the cron job, the newest one has
+		// the pods most likely to still be around
+		job, err := lastJob(ctx, c, namespace, o)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			w.Selector = job.Spec.Selector
+		}
+
+	case KindPod:
+		o, err := c.CoreV1().Pods(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec, w.ResourceVersion = &o.Spec, o.ResourceVersion
+
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %s, use one of %v", ref.Kind, WorkloadKinds)
+	}
+
+	return w, nil
+}
+
+func lastJob(ctx context.Context, c kubernetes.Interface, namespace string, cj *batchv1.CronJob) (*batchv1.Job, error) {
+	jobs, err := c.BatchV1().Jobs(namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var last *batchv1.Job
+	for i := range jobs.Items {
+		j := &jobs.Items[i]
+		if ownedBy(j.OwnerReferences, cj.UID) && (last == nil || j.CreationTimestamp.After(last.CreationTimestamp.Time)) {
+			last = j
+		}
+	}
+
+	return last, nil
+}
+
+func ownedBy(refs []v1.OwnerReference, uid types.UID) bool {
+	return slices.ContainsFunc(refs, func(r v1.OwnerReference) bool {
+		return r.UID == uid
+	})
+}
+
+// ListWorkloads lists the workloads of every kind in namespace. Replica sets,
+// jobs and pods managed by another workload are left out.
+func (c *Client) ListWorkloads(ctx context.Context, namespace string) ([]Workload, error) {
+	return listWorkloads(ctx, c.Clientset, namespace)
+}
+
+func listWorkloads(ctx context.Context, c kubernetes.Interface, namespace string) ([]Workload, error) {
+	var ws []Workload
+	add := func(kind WorkloadKind, meta v1.ObjectMeta, spec *corev1.PodSpec, selector *v1.LabelSelector) {
+		ws = append(ws, Workload{WorkloadRef: WorkloadRef{Kind: kind, Name: meta.Name}, Spec: spec, Selector: selector})
+	}
+
+	opts := v1.ListOptions{}
+
+	deployments, err := c.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range deployments.Items {
+		add(KindDeployment, o.ObjectMeta, &deployments.Items[i].Spec.Template.Spec, o.Spec.Selector)
+	}
+
+	statefulSets, err := c.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range statefulSets.Items {
+		add(KindStatefulSet, o.ObjectMeta, &statefulSets.Items[i].Spec.Template.Spec, o.Spec.Selector)
+	}
+
+	daemonSets, err := c.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range daemonSets.Items {
+		add(KindDaemonSet, o.ObjectMeta, &daemonSets.Items[i].Spec.Template.Spec, o.Spec.Selector)
+	}
+
+	replicaSets, err := c.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range replicaSets.Items {
+		if !isManaged(o.ObjectMeta) {
+			add(KindReplicaSet, o.ObjectMeta, &replicaSets.Items[i].Spec.Template.Spec, o.Spec.Selector)
+		}
+	}
+
+	jobs, err := c.BatchV1().Jobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range jobs.Items {
+		if !isManaged(o.ObjectMeta) {
+			add(KindJob, o.ObjectMeta, &jobs.Items[i].Spec.Template.Spec, o.Spec.Selector)
+		}
+	}
+
+	cronJobs, err := c.BatchV1().CronJobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range cronJobs.Items {
+		add(KindCronJob, o.ObjectMeta, &cronJobs.Items[i].Spec.JobTemplate.Spec.Template.Spec, nil)
+	}
+
+	pods, err := c.CoreV1().Pods(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range pods.Items {
+		if !isManaged(o.ObjectMeta) {
+			add(KindPod, o.ObjectMeta, &pods.Items[i].Spec, nil)
+		}
+	}
+
+	return ws, nil
+}
+
+// isManaged tells whether a controller owns the object.
+func isManaged(meta v1.ObjectMeta) bool {
+	return v1.GetControllerOfNoCopy(&meta) != nil
+}
@@ -0,0 +1,155 @@
+package kube
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func meta(name string, owner *v1.OwnerReference) v1.ObjectMeta {
+	m := v1.ObjectMeta{Name: name, Namespace: testNamespace, UID: types.UID("uid-" + name)}
+	if owner != nil {
+		m.OwnerReferences = []v1.OwnerReference{*owner}
+	}
+	return m
+}
+
+func controller(kind, name string) *v1.OwnerReference {
+	yes := true
+	return &v1.OwnerReference{Kind: kind, Name: name, UID: types.UID("uid-" + name), Controller: &yes}
+}
+
+func template(container string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: container}}}}
+}
+
+func selector(app string) *v1.LabelSelector {
+	return &v1.LabelSelector{MatchLabels: map[string]string{"app": app}}
+}
+
+func TestWorkloads(t *testing.T) {
+	old := batchv1.Job{ObjectMeta: meta("report-1", controller("CronJob", "report")), Spec: batchv1.JobSpec{Selector: selector("report-1"), Template: template("report")}}
+	old.CreationTimestamp = v1.NewTime(time.Now().Add(-time.Hour))
+	last := batchv1.Job{ObjectMeta: meta("report-2", controller("CronJob", "report")), Spec: batchv1.JobSpec{Selector: selector("report-2"), Template: template("report")}}
+	last.CreationTimestamp = v1.NewTime(time.Now())
+
+	c := fake.NewClientset(
+		&appsv1.Deployment{ObjectMeta: meta("api", nil), Spec: appsv1.DeploymentSpec{Selector: selector("api"), Template: template("api")}},
+		&appsv1.ReplicaSet{ObjectMeta: meta("api-7d9f", controller("Deployment", "api")), Spec: appsv1.ReplicaSetSpec{Selector: selector("api"), Template: template("api")}},
+		&appsv1.StatefulSet{ObjectMeta: meta("db", nil), Spec: appsv1.StatefulSetSpec{Selector: selector("db"), Template: template("postgres")}},
+		&appsv1.DaemonSet{ObjectMeta: meta("agent", nil), Spec: appsv1.DaemonSetSpec{Selector: selector("agent"), Template: template("agent")}},
+		&batchv1.CronJob{ObjectMeta: meta("report", nil), Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: template("report")}}}},
+		&old, &last,
+		&batchv1.Job{ObjectMeta: meta("migrate", nil), Spec: batchv1.JobSpec{Selector: selector("migrate"), Template: template("migrate")}},
+		&corev1.Pod{ObjectMeta: meta("api-7d9f-x2kqp", controller("ReplicaSet", "api-7d9f")), Spec: template("api").Spec},
+		&corev1.Pod{ObjectMeta: meta("debug", nil), Spec: template("shell").Spec},
+	)
+
+	t.Run("list leaves out managed workloads", func(t *testing.T) {
+		ws, err := listWorkloads(context.Background(), c, testNamespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, w := range ws {
+			got = append(got, w.String())
+		}
+
+		want := []string{"deployment/api", "statefulset/db", "daemonset/agent", "job/migrate", "cronjob/report", "pod/debug"}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	tests := []struct {
+		ref       WorkloadRef
+		container string
+		selector  string
+		wantErr   bool
+	}{
+		{ref: WorkloadRef{Name: "api"}, container: "api", selector: "api"},
+		{ref: WorkloadRef{Kind: KindStatefulSet, Name: "db"}, container: "postgres", selector: "db"},
+		{ref: WorkloadRef{Kind: KindDaemonSet, Name: "agent"}, container: "agent", selector: "agent"},
+		{ref: WorkloadRef{Kind: KindReplicaSet, Name: "api-7d9f"}, container: "api", selector: "api"},
+		{ref: WorkloadRef{Kind: KindJob, Name: "migrate"}, container: "migrate", selector: "migrate"},
+		{ref: WorkloadRef{Kind: KindCronJob, Name: "report"}, container: "report", selector: "report-2"},
+		{ref: WorkloadRef{Kind: KindPod, Name: "debug"}, container: "shell"},
+		{ref: WorkloadRef{Kind: KindStatefulSet, Name: "api"}, wantErr: true},
+		{ref: WorkloadRef{Kind: "service", Name: "api"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref.String(), func(t *testing.T) {
+			w, err := getWorkload(context.Background(), c, testNamespace, tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", w)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := w.Spec.Containers[0].Name; got != tt.container {
+				t.Errorf("got container %s, want %s", got, tt.container)
+			}
+
+			var got string
+			if w.Selector != nil {
+				got = w.Selector.MatchLabels["app"]
+			}
+			if got != tt.selector {
+				t.Errorf("got selector %q, want %q", got, tt.selector)
+			}
+		})
+	}
+}
+
+func TestContainer(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	w := &Workload{
+		WorkloadRef: WorkloadRef{Kind: KindDeployment, Name: "api"},
+		Spec: &corev1.PodSpec{
+			Containers:     []corev1.Container{{Name: "api"}, {Name: "proxy"}},
+			InitContainers: []corev1.Container{{Name: "migrate"}, {Name: "vault", RestartPolicy: &always}, {Name: "api"}},
+		},
+	}
+
+	if got, want := w.ContainerNames(), []string{"api", "proxy", "init:migrate", "init:vault", "init:api"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for name, running := range map[string]bool{"api": true, "proxy": true, "init:migrate": false, "init:vault": true} {
+		c, err := w.Container(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if isRunning(name, c) != running {
+			t.Errorf("%s: running %v, want %v", name, !running, running)
+		}
+	}
+
+	// an init container named like a container is only found with the prefix
+	if c, _ := w.Container("init:api"); c != &w.Spec.InitContainers[2] {
+		t.Errorf("init:api is not the init container")
+	}
+
+	for _, name := range []string{"migrate", "init:proxy", "init:"} {
+		if _, err := w.Container(name); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/abdheshnayak/inkube/flags"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+// KeyFile is the default age identity file, the one SOPS reads.
+func KeyFile() string {
+	return filepath.Join(flags.ConfigHome, "sops", "age", "keys.txt")
+}
+
+var identities = sync.OnceValues(readIdentities)
+
+// Identities returns the age identities of the user, read like SOPS does
+// from $SOPS_AGE_KEY and from $SOPS_AGE_KEY_FILE or KeyFile.
+func Identities() ([]age.Identity, error) {
+	return identities()
+}
+
+func readIdentities() ([]age.Identity, error) {
+	var ids []age.Identity
+
+	if k := os.Getenv("SOPS_AGE_KEY"); k != "" {
+		parsed, err := age.ParseIdentities(strings.NewReader(k))
+		if err != nil {
+			return nil, fn.Errorf("invalid SOPS_AGE_KEY: %w", err)
+		}
+		ids = append(ids, parsed...)
+	}
+
+	file, explicit := os.LookupEnv("SOPS_AGE_KEY_FILE")
+	if !explicit {
+		file = KeyFile()
+	}
+
+	f, err := os.Open(file)
+	switch {
+	case err == nil:
+		defer f.Close()
+		parsed, err := age.ParseIdentities(f)
+		if err != nil {
+			return nil, fn.Errorf("invalid age identity file %s: %w", file, err)
+		}
+		ids = append(ids, parsed...)
+	case !os.IsNotExist(err) || explicit:
+		return nil, fn.Errorf("failed to read the age identity file %s: %w", file, err)
+	}
+
+	if len(ids) == 0 {
+		return nil, fn.Errorf("no age identity found, put yours in %s or set SOPS_AGE_KEY_FILE, `age-keygen -o %s` creates one", KeyFile(), KeyFile())
+	}
+
+	return ids, nil
+}
+
+// Own returns the recipients of the identities, to encrypt files for
+// oneself.
+func Own(ids []age.Identity) []*age.X25519Recipient {
+	var rs []*age.X25519Recipient
+	for _, id := range ids {
+		if x, ok := id.(*age.X25519Identity); ok {
+			rs = append(rs, x.Recipient())
+		}
+	}
+	return rs
+}
+
+// ParseRecipients parses age recipients, like age1..., skipping empty
+// lines and comments the way recipient files are written.
+func ParseRecipients(ss ...string) ([]*age.X25519Recipient, error) {
+	var rs []*age.X25519Recipient
+	for _, s := range ss {
+		s = strings.TrimSpace(s)
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		r, err := age.ParseX25519Recipient(s)
+		if err != nil {
+			return nil, fn.Errorf("invalid age recipient %q: %w", s, err)
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func recipients(rs []*age.X25519Recipient) []age.Recipient {
+	res := make([]age.Recipient, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, r)
+	}
+	return res
+}
+
+func isArmored(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte(armor.Header))
+}
+
+func decryptAge(b []byte, ids []age.Identity) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(b)
+	if isArmored(b) {
+		r = armor.NewReader(r)
+	}
+
+	d, err := age.Decrypt(r, ids...)
+	if err != nil {
+		return nil, noMatch(err)
+	}
+
+	return io.ReadAll(d)
+}
+
+func encryptAge(plain []byte, rs []*age.X25519Recipient, armored bool) ([]byte, error) {
+	if len(rs) == 0 {
+		return nil, fn.Errorf("no recipients to encrypt for")
+	}
+
+	var buf bytes.Buffer
+	var dst io.WriteCloser = nopCloser{&buf}
+	if armored {
+		dst = armor.NewWriter(&buf)
+	}
+
+	w, err := age.Encrypt(dst, recipients(rs)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(plain); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := dst.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// noMatch explains what to do when none of the identities of the user is a
+// recipient of the file.
+func noMatch(err error) error {
+	var e *age.NoIdentityMatchError
+	if errors.As(err, &e) {
+		return fn.Errorf("none of your age identities is a recipient, ask someone who can decrypt it to add your public key")
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+// Package secrets decrypts and encrypts the files of loadEnv.overridesFrom
+// that are committed encrypted, in formats compatible with age and SOPS:
+//
+//   - name.age, a file encrypted with age, armored or not
+//   - name.enc.yaml, a YAML file encrypted with SOPS, each value is encrypted
+//     with a data key, itself encrypted with age for each recipient
+//
+// The keys are read from the age identity file, see Identities.
+package secrets
+
+import (
+	"strings"
+
+	"filippo.io/age"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+)
+
+const (
+	suffixAge     = ".age"
+	suffixSops    = ".enc.yaml"
+	suffixSopsYml = ".enc.yml"
+)
+
+// IsEncrypted tells whether the file name is encrypted, from its extension.
+func IsEncrypted(name string) bool {
+	return isAge(name) || isSops(name)
+}
+
+func isAge(name string) bool {
+	return strings.HasSuffix(name, suffixAge)
+}
+
+func isSops(name string) bool {
+	return strings.HasSuffix(name, suffixSops) || strings.HasSuffix(name, suffixSopsYml)
+}
+
+// Plain returns the name of the decrypted file, like secrets.env for
+// secrets.env.age or secrets.yaml for secrets.enc.yaml.
+func Plain(name string) string {
+	switch {
+	case isAge(name):
+		return strings.TrimSuffix(name, suffixAge)
+	case strings.HasSuffix(name, suffixSops):
+		return strings.TrimSuffix(name, suffixSops) + ".yaml"
+	case strings.HasSuffix(name, suffixSopsYml):
+		return strings.TrimSuffix(name, suffixSopsYml) + ".yml"
+	}
+	return name
+}
+
+// Decrypt decrypts b, the content of the file name.
+func Decrypt(name string, b []byte) ([]byte, error) {
+	ids, err := Identities()
+	if err != nil {
+		return nil, err
+	}
+
+	var plain []byte
+	switch {
+	case isSops(name):
+		plain, err = decryptSops(b, ids)
+	case isAge(name):
+		plain, err = decryptAge(b, ids)
+	default:
+		return nil, fn.Errorf("%s is not encrypted, expected a name ending in %s or %s", name, suffixSops, suffixAge)
+	}
+
+	if err != nil {
+		return nil, fn.Errorf("failed to decrypt %s: %w", name, err)
+	}
+	return plain, nil
+}
+
+// Encrypt encrypts plain, the new content of the file name. old is its
+// current content, nil for a new file.
+//
+// age files are encrypted for the recipients. SOPS files keep their data key
+// and recipients, the recipients missing from the file are added.
+func Encrypt(name string, plain, old []byte, recipients []*age.X25519Recipient) ([]byte, error) {
+	var b []byte
+	var err error
+
+	switch {
+	case isSops(name):
+		var ids []age.Identity
+		if old != nil {
+			if ids, err = Identities(); err != nil {
+				return nil, err
+			}
+		}
+		b, err = encryptSops(plain, old, ids, recipients)
+	case isAge(name):
+		b, err = encryptAge(plain, recipients, old == nil || isArmored(old))
+	default:
+		return nil, fn.Errorf("%s can't be encrypted, expected a name ending in %s or %s", name, suffixSops, suffixAge)
+	}
+
+	if err != nil {
+		return nil, fn.Errorf("failed to encrypt %s: %w", name, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"gopkg.in/yaml.v3"
+)
+
+const sopsConfigName = ".sops.yaml"
+
+// sopsConfig is the .sops.yaml of a project, only the age recipients of its
+// creation rules are used.
+type sopsConfig struct {
+	CreationRules []struct {
+		PathRegex string  `yaml:"path_regex"`
+		Age       ageList `yaml:"age"`
+	} `yaml:"creation_rules"`
+}
+
+// ageList is a list of recipients, written as a comma separated string or
+// as a list.
+type ageList []string
+
+func (l *ageList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = strings.Split(n.Value, ",")
+		return nil
+	}
+
+	var s []string
+	if err := n.Decode(&s); err != nil {
+		return err
+	}
+	*l = s
+	return nil
+}
+
+// Recipients returns the recipients the file name is encrypted for by the
+// first creation rule matching it in the nearest .sops.yaml, nil when there
+// is none.
+func Recipients(name string) ([]*age.X25519Recipient, error) {
+	p, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		b, err := os.ReadFile(filepath.Join(dir, sopsConfigName))
+		if err == nil {
+			return creationRule(dir, p, b)
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, fn.Errorf("failed to read %s: %w", sopsConfigName, err)
+		}
+
+		if filepath.Dir(dir) == dir {
+			return nil, nil
+		}
+	}
+}
+
+func creationRule(dir, file string, b []byte) ([]*age.X25519Recipient, error) {
+	var c sopsConfig
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, fn.Errorf("invalid %s: %w", filepath.Join(dir, sopsConfigName), err)
+	}
+
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, r := range c.CreationRules {
+		if r.PathRegex != "" {
+			re, err := regexp.Compile(r.PathRegex)
+			if err != nil {
+				return nil, fn.Errorf("invalid path_regex %q in %s: %w", r.PathRegex, sopsConfigName, err)
+			}
+			if !re.MatchString(rel) {
+				continue
+			}
+		}
+
+		return ParseRecipients(r.Age...)
+	}
+
+	return nil, nil
+}
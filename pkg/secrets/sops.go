@@ -0,0 +1,677 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/abdheshnayak/inkube/pkg/fn"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	sopsKey     = "sops"
+	sopsVersion = "3.9.0"
+
+	// sopsNonceSize is the size of the IVs of SOPS, larger than the standard
+	// GCM nonce.
+	sopsNonceSize = 32
+)
+
+var sopsValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// macOnlyEncryptedInit starts the MAC of files with mac_only_encrypted, so
+// that it differs from the MAC of the same file without it.
+var macOnlyEncryptedInit = []byte{0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0xb, 0xb, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69}
+
+// sopsMeta is the sops key of a SOPS file.
+type sopsMeta struct {
+	Age               []ageKey `yaml:"age"`
+	LastModified      string   `yaml:"lastmodified"`
+	MAC               string   `yaml:"mac"`
+	UnencryptedSuffix string   `yaml:"unencrypted_suffix,omitempty"`
+	EncryptedSuffix   string   `yaml:"encrypted_suffix,omitempty"`
+	UnencryptedRegex  string   `yaml:"unencrypted_regex,omitempty"`
+	EncryptedRegex    string   `yaml:"encrypted_regex,omitempty"`
+	MACOnlyEncrypted  bool     `yaml:"mac_only_encrypted,omitempty"`
+	Version           string   `yaml:"version"`
+
+	UnencryptedCommentRegex string `yaml:"unencrypted_comment_regex,omitempty"`
+	EncryptedCommentRegex   string `yaml:"encrypted_comment_regex,omitempty"`
+}
+
+// ageKey is the data key encrypted for a recipient.
+type ageKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+// sopsFile is a parsed SOPS file, tree is the document without the sops key
+// and node the sops key, kept to write back the fields inkube doesn't know,
+// like the KMS keys.
+type sopsFile struct {
+	doc  *yaml.Node
+	tree *yaml.Node
+	node *yaml.Node
+	meta sopsMeta
+}
+
+func parseSops(b []byte) (*sopsFile, error) {
+	f, err := parseTree(b)
+	if err != nil {
+		return nil, err
+	}
+
+	i := slices.IndexFunc(f.tree.Content, func(n *yaml.Node) bool { return n.Value == sopsKey })
+	if i < 0 || i%2 != 0 {
+		return nil, fn.Errorf("the sops key is missing, it isn't a SOPS file")
+	}
+
+	// comments of the sops key belong to the document, like in SOPS
+	k := f.tree.Content[i]
+	for _, c := range []string{k.HeadComment, k.LineComment, k.FootComment} {
+		if c != "" {
+			f.tree.FootComment = strings.TrimPrefix(f.tree.FootComment+"\n"+c, "\n")
+		}
+	}
+
+	f.node = f.tree.Content[i+1]
+	f.tree.Content = slices.Delete(f.tree.Content, i, i+2)
+
+	if err := f.node.Decode(&f.meta); err != nil {
+		return nil, fn.Errorf("invalid sops key: %w", err)
+	}
+
+	if f.meta.MAC == "" {
+		return nil, fn.Errorf("the sops key has no mac")
+	}
+
+	return f, nil
+}
+
+// parseTree parses a YAML document whose root is a map, an empty document is
+// an empty map.
+func parseTree(b []byte) (*sopsFile, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fn.Errorf("expected a map of values")
+	}
+
+	return &sopsFile{doc: &doc, tree: doc.Content[0]}, nil
+}
+
+// dataKey decrypts the data key with the first identity it is encrypted for.
+func (f *sopsFile) dataKey(ids []age.Identity) ([]byte, error) {
+	if len(f.meta.Age) == 0 {
+		return nil, fn.Errorf("the file has no age recipients, only age is supported")
+	}
+
+	var err error
+	for _, k := range f.meta.Age {
+		var key []byte
+		if key, err = decryptAge([]byte(k.Enc), ids); err == nil {
+			return key, nil
+		}
+	}
+
+	return nil, err
+}
+
+// encrypts returns the rule telling whether the value at a path is encrypted,
+// from the *_suffix and *_regex fields of the sops key, applied in the order
+// SOPS applies them.
+func (m *sopsMeta) encrypts() (func(path []string) bool, error) {
+	if m.UnencryptedCommentRegex != "" || m.EncryptedCommentRegex != "" {
+		return nil, fn.Errorf("unencrypted_comment_regex and encrypted_comment_regex of SOPS are not supported")
+	}
+
+	var unencryptedRe, encryptedRe *regexp.Regexp
+	for _, r := range []struct {
+		re  **regexp.Regexp
+		src string
+	}{{&unencryptedRe, m.UnencryptedRegex}, {&encryptedRe, m.EncryptedRegex}} {
+		if r.src == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(r.src)
+		if err != nil {
+			return nil, fn.Errorf("invalid sops regex: %w", err)
+		}
+		*r.re = re
+	}
+
+	suffix := func(s string) func(string) bool {
+		return func(k string) bool { return strings.HasSuffix(k, s) }
+	}
+
+	return func(path []string) bool {
+		encrypted := true
+		if m.UnencryptedSuffix != "" && slices.ContainsFunc(path, suffix(m.UnencryptedSuffix)) {
+			encrypted = false
+		}
+		if m.EncryptedSuffix != "" {
+			encrypted = slices.ContainsFunc(path, suffix(m.EncryptedSuffix))
+		}
+		if unencryptedRe != nil && slices.ContainsFunc(path, unencryptedRe.MatchString) {
+			encrypted = false
+		}
+		if encryptedRe != nil {
+			encrypted = slices.ContainsFunc(path, encryptedRe.MatchString)
+		}
+		return encrypted
+	}, nil
+}
+
+// walk calls f on the scalars of n in order along with their path, and c on
+// the comments, the way SOPS walks a file: the items of a list share the path
+// of the list, and comments have the path of the map or list holding them.
+func walk(n *yaml.Node, path []string, f func(n *yaml.Node, path []string) error, c func(comment *string, path []string) error) error {
+	comments := func(n *yaml.Node) error {
+		for _, s := range []*string{&n.HeadComment, &n.LineComment, &n.FootComment} {
+			if *s == "" {
+				continue
+			}
+			if err := c(s, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// values of maps and items of lists, a scalar's comments are the ones of
+	// its parent
+	value := func(v *yaml.Node, p []string) error {
+		switch v.Kind {
+		case yaml.ScalarNode:
+			if err := comments(v); err != nil {
+				return err
+			}
+			return f(v, p)
+		case yaml.AliasNode:
+			return fn.Errorf("%s: aliases are not supported", strings.Join(p, "."))
+		}
+		return walk(v, p, f, c)
+	}
+
+	if err := comments(n); err != nil {
+		return err
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, v := range n.Content {
+			if err := walk(v, path, f, c); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if err := comments(n.Content[i]); err != nil {
+				return err
+			}
+			if err := value(n.Content[i+1], append(slices.Clip(path), n.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, v := range n.Content {
+			if err := value(v, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mapComment calls f on every line of a comment, without its #.
+func mapComment(comment string, f func(s string) (string, error)) (string, error) {
+	lines := strings.Split(comment, "\n")
+	for i, l := range lines {
+		if len(l) < 2 || l[0] != '#' {
+			continue
+		}
+
+		v, err := f(l[1:])
+		if err != nil {
+			return "", err
+		}
+		lines[i] = "#" + v
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// additionalData authenticates a value along with its path, so that values
+// can't be moved around.
+func additionalData(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+// scalar returns the bytes and the SOPS type of a plain value, the bytes are
+// both the ones encrypted and the ones hashed in the MAC. Bools are True and
+// False, like in the Python version of SOPS.
+func scalar(n *yaml.Node) ([]byte, string, error) {
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, "", err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil, "", nil
+	case string:
+		return []byte(v), "str", nil
+	case int:
+		return []byte(strconv.Itoa(v)), "int", nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), "float", nil
+	case bool:
+		if v {
+			return []byte("True"), "bool", nil
+		}
+		return []byte("False"), "bool", nil
+	case time.Time:
+		b, err := v.MarshalText()
+		return b, "time", err
+	}
+	return []byte(n.Value), "str", nil
+}
+
+// typed turns a decrypted value of a SOPS type back into a YAML scalar.
+func typed(v, typ string) (*yaml.Node, error) {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	var err error
+
+	switch typ {
+	case "str":
+	case "int":
+		var i int
+		i, err = strconv.Atoi(v)
+		n.Tag, n.Value = "!!int", strconv.Itoa(i)
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(v, 64)
+		n.Tag, n.Value = "!!float", strconv.FormatFloat(f, 'f', -1, 64)
+	case "bool":
+		var b bool
+		b, err = strconv.ParseBool(v)
+		n.Tag, n.Value = "!!bool", strconv.FormatBool(b)
+	case "time":
+		var t time.Time
+		err = t.UnmarshalText([]byte(v))
+		n.Tag, n.Value = "!!timestamp", t.Format(time.RFC3339Nano)
+	default:
+		return nil, fn.Errorf("unknown type %s", typ)
+	}
+
+	if err != nil {
+		return nil, fn.Errorf("invalid %s value: %w", typ, err)
+	}
+	return n, nil
+}
+
+func decryptSops(b []byte, ids []age.Identity) ([]byte, error) {
+	f, err := parseSops(b)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := f.dataKey(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypts, err := f.meta.encrypts()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := f.newMAC()
+
+	// SOPS writes the comments of lists as items, they are turned back into
+	// comments once decrypted
+	listComments := map[*yaml.Node]bool{}
+
+	err = walk(f.tree, nil, func(n *yaml.Node, path []string) error {
+		encrypted := encrypts(path)
+		if !encrypted || n.Value == "" || n.ShortTag() == "!!null" {
+			return f.hash(mac, n, encrypted)
+		}
+
+		v, typ, err := decryptValue(n.Value, key, additionalData(path))
+		if err != nil {
+			return fn.Errorf("%s: %w", strings.Join(path, "."), err)
+		}
+
+		if typ == "comment" {
+			n.Value = v
+			listComments[n] = true
+			return nil
+		}
+
+		p, err := typed(v, typ)
+		if err != nil {
+			return fn.Errorf("%s: %w", strings.Join(path, "."), err)
+		}
+
+		b, _, err := scalar(p)
+		if err != nil {
+			return err
+		}
+		mac.Write(b)
+
+		n.Value, n.Tag, n.Style = p.Value, p.Tag, 0
+		return nil
+	}, func(c *string, path []string) error {
+		if !encrypts(path) {
+			return nil
+		}
+
+		// like SOPS, comments that don't decrypt are taken as plain ones
+		*c, _ = mapComment(*c, func(s string) (string, error) {
+			if v, typ, err := decryptValue(s, key, additionalData(path)); err == nil && typ == "comment" {
+				return v, nil
+			}
+			return s, nil
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unlistComments(f.tree, listComments)
+
+	if err := f.verify(mac, key); err != nil {
+		return nil, err
+	}
+
+	return marshal(f.doc)
+}
+
+func (f *sopsFile) newMAC() hash.Hash {
+	mac := sha512.New()
+	if f.meta.MACOnlyEncrypted {
+		mac.Write(macOnlyEncryptedInit)
+	}
+	return mac
+}
+
+// unlistComments turns the items of lists in comments into comments of the
+// next item, or of the last one.
+func unlistComments(n *yaml.Node, comments map[*yaml.Node]bool) {
+	for _, c := range n.Content {
+		unlistComments(c, comments)
+	}
+
+	if n.Kind != yaml.SequenceNode || len(comments) == 0 {
+		return
+	}
+
+	var pending []string
+	var content []*yaml.Node
+	for _, c := range n.Content {
+		if comments[c] {
+			pending = append(pending, "#"+c.Value)
+			continue
+		}
+
+		if len(pending) > 0 {
+			c.HeadComment = strings.TrimSuffix(strings.Join(append(pending, c.HeadComment), "\n"), "\n")
+			pending = nil
+		}
+		content = append(content, c)
+	}
+
+	if len(pending) > 0 {
+		if len(content) == 0 {
+			n.HeadComment = strings.Join(pending, "\n")
+		} else {
+			last := content[len(content)-1]
+			last.FootComment = strings.TrimPrefix(last.FootComment+"\n"+strings.Join(pending, "\n"), "\n")
+		}
+	}
+	n.Content = content
+}
+
+func (f *sopsFile) hash(mac hash.Hash, n *yaml.Node, encrypted bool) error {
+	if f.meta.MACOnlyEncrypted && !encrypted {
+		return nil
+	}
+
+	b, _, err := scalar(n)
+	if err != nil {
+		return err
+	}
+	mac.Write(b)
+	return nil
+}
+
+func (f *sopsFile) verify(mac hash.Hash, key []byte) error {
+	modified, err := time.Parse(time.RFC3339, f.meta.LastModified)
+	if err != nil {
+		return fn.Errorf("invalid sops.lastmodified: %w", err)
+	}
+
+	want, _, err := decryptValue(f.meta.MAC, key, modified.Format(time.RFC3339))
+	if err != nil {
+		return fn.Errorf("invalid sops.mac: %w", err)
+	}
+
+	if fmt.Sprintf("%X", mac.Sum(nil)) != want {
+		return fn.Errorf("the MAC doesn't match, the file was changed without its key")
+	}
+	return nil
+}
+
+// encryptSops encrypts the YAML document plain. old is the current content
+// of the file, its data key and sops key are kept.
+func encryptSops(plain, old []byte, ids []age.Identity, rs []*age.X25519Recipient) ([]byte, error) {
+	f, err := parseTree(plain)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.ContainsFunc(f.tree.Content, func(n *yaml.Node) bool { return n.Value == sopsKey }) {
+		return nil, fn.Errorf("the key sops is used by SOPS, please rename it")
+	}
+
+	var key []byte
+	if old == nil {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		f.meta = sopsMeta{UnencryptedSuffix: "_unencrypted", Version: sopsVersion}
+		f.node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	} else {
+		o, err := parseSops(old)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = o.dataKey(ids); err != nil {
+			return nil, err
+		}
+		f.meta, f.node = o.meta, o.node
+	}
+
+	for _, r := range rs {
+		if slices.ContainsFunc(f.meta.Age, func(k ageKey) bool { return k.Recipient == r.String() }) {
+			continue
+		}
+
+		enc, err := encryptAge(key, []*age.X25519Recipient{r}, true)
+		if err != nil {
+			return nil, err
+		}
+		f.meta.Age = append(f.meta.Age, ageKey{Recipient: r.String(), Enc: string(enc)})
+	}
+
+	if len(f.meta.Age) == 0 {
+		return nil, fn.Errorf("no recipients to encrypt for")
+	}
+
+	encrypts, err := f.meta.encrypts()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := f.newMAC()
+	err = walk(f.tree, nil, func(n *yaml.Node, path []string) error {
+		encrypted := encrypts(path)
+		if err := f.hash(mac, n, encrypted); err != nil {
+			return err
+		}
+
+		b, typ, err := scalar(n)
+		if err != nil || !encrypted || len(b) == 0 {
+			return err
+		}
+
+		v, err := encryptValue(b, typ, key, additionalData(path))
+		if err != nil {
+			return err
+		}
+		n.Value, n.Tag, n.Style = v, "!!str", 0
+		return nil
+	}, func(c *string, path []string) error {
+		// comments are encrypted too, but not part of the MAC
+		if !encrypts(path) {
+			return nil
+		}
+
+		var err error
+		*c, err = mapComment(*c, func(s string) (string, error) {
+			if s == "" {
+				return s, nil
+			}
+			return encryptValue([]byte(s), "comment", key, additionalData(path))
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	f.meta.LastModified = time.Now().UTC().Format(time.RFC3339)
+	if f.meta.MAC, err = encryptValue([]byte(fmt.Sprintf("%X", mac.Sum(nil))), "str", key, f.meta.LastModified); err != nil {
+		return nil, err
+	}
+
+	if err := f.writeMeta(); err != nil {
+		return nil, err
+	}
+
+	f.tree.Content = append(f.tree.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: sopsKey}, f.node)
+	return marshal(f.doc)
+}
+
+// writeMeta sets the fields of f.meta in f.node, keeping the other ones.
+func (f *sopsFile) writeMeta() error {
+	var n yaml.Node
+	if err := n.Encode(f.meta); err != nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		j := slices.IndexFunc(f.node.Content, func(c *yaml.Node) bool { return c.Value == k.Value })
+		if j >= 0 && j%2 == 0 {
+			f.node.Content[j+1] = v
+			continue
+		}
+		f.node.Content = append(f.node.Content, k, v)
+	}
+	return nil
+}
+
+func marshal(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	e := yaml.NewEncoder(&buf)
+	e.SetIndent(4)
+	if err := e.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decryptValue(s string, key []byte, ad string) (string, string, error) {
+	m := sopsValue.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", fn.Errorf("the value is not encrypted")
+	}
+
+	var parts [3][]byte
+	for i := range parts {
+		b, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return "", "", fn.Errorf("invalid encrypted value: %w", err)
+		}
+		parts[i] = b
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	gcm, err := sopsCipher(key, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(ad))
+	if err != nil {
+		return "", "", fn.Errorf("failed to decrypt the value: %w", err)
+	}
+
+	return string(plain), m[4], nil
+}
+
+func encryptValue(plain []byte, typ string, key []byte, ad string) (string, error) {
+	iv := make([]byte, sopsNonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	gcm, err := sopsCipher(key, len(iv))
+	if err != nil {
+		return "", err
+	}
+
+	out := gcm.Seal(nil, iv, plain, []byte(ad))
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag),
+		typ,
+	), nil
+}
+
+func sopsCipher(key []byte, nonceSize int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fn.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
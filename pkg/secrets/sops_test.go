@@ -0,0 +1,292 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+func identity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestSops(t *testing.T) {
+	id := identity(t)
+	ids := []age.Identity{id}
+
+	plain := []byte("DB_PASSWORD: hunter2\nPORT: 5432\nDEBUG: true\nEMPTY: \"\"\nREGION_unencrypted: eu\nnested:\n  token: t0k3n\n")
+
+	b, err := encryptSops(plain, nil, nil, []*age.X25519Recipient{id.Recipient()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"hunter2", "5432", "t0k3n"} {
+		if bytes.Contains(b, []byte(s)) {
+			t.Errorf("%q is in the encrypted file:\n%s", s, b)
+		}
+	}
+
+	if !bytes.Contains(b, []byte("REGION_unencrypted: eu")) {
+		t.Errorf("the unencrypted value was encrypted:\n%s", b)
+	}
+
+	got, err := decryptSops(b, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, have map[string]any
+	_ = yaml.Unmarshal(plain, &want)
+	_ = yaml.Unmarshal(got, &have)
+	if !equal(want, have) {
+		t.Errorf("got\n%s\nwant\n%s", got, plain)
+	}
+
+	// editing keeps the data key
+	b2, err := encryptSops([]byte("DB_PASSWORD: hunter3\n"), b, ids, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f1, _ := parseSops(b)
+	f2, _ := parseSops(b2)
+	if f1.meta.Age[0].Enc != f2.meta.Age[0].Enc {
+		t.Errorf("the data key changed")
+	}
+
+	if _, err := decryptSops(b, []age.Identity{identity(t)}); err == nil {
+		t.Errorf("expected an error for another identity")
+	}
+
+	tampered := bytes.Replace(b, []byte("REGION_unencrypted: eu"), []byte("REGION_unencrypted: us"), 1)
+	if _, err := decryptSops(tampered, ids); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Errorf("expected a MAC error, got %v", err)
+	}
+
+	// values can't be moved to another key
+	var doc yaml.Node
+	_ = yaml.Unmarshal(b, &doc)
+	m := doc.Content[0].Content
+	m[1].Value, m[3].Value = m[3].Value, m[1].Value
+	swapped, _ := yaml.Marshal(&doc)
+	if _, err := decryptSops(swapped, ids); err == nil {
+		t.Errorf("expected an error for swapped values")
+	}
+}
+
+// fixtureKey is the identity the files of testdata are encrypted for, they
+// were written by sops 3.10.2 from testdata/plain.yaml, regex.enc.yaml with
+// encrypted_regex ^(DB_PASSWORD|nested)$ and mac_only_encrypted.
+const fixtureKey = "AGE-SECRET-KEY-1XGE8YFQDRM7R3GLRQN7QFXEKNDSSPHTQWWWKRAQGEP3QLUENPEKQY59WV3"
+
+func TestSopsFixtures(t *testing.T) {
+	id, err := age.ParseX25519Identity(fixtureKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []age.Identity{id}
+
+	plain, err := os.ReadFile("testdata/plain.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want map[string]any
+	_ = yaml.Unmarshal(plain, &want)
+
+	comments := []string{"# shared with the staging cluster", "# rotated monthly", "# the API token", "# second item"}
+
+	for _, name := range []string{"sops.enc.yaml", "regex.enc.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decryptSops(b, ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var have map[string]any
+			_ = yaml.Unmarshal(got, &have)
+			if !equal(want, have) {
+				t.Errorf("got\n%s\nwant\n%s", got, plain)
+			}
+
+			for _, c := range comments {
+				if !strings.Contains(string(got), c) {
+					t.Errorf("comment %q is missing:\n%s", c, got)
+				}
+			}
+			if strings.Contains(string(got), "ENC[") {
+				t.Errorf("a value is still encrypted:\n%s", got)
+			}
+
+			// written again, comments are encrypted like values
+			enc, err := encryptSops(got, b, ids, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, _ := parseSops(enc)
+			encrypts, _ := f.meta.encrypts()
+			if encrypts([]string{"nested"}) && strings.Contains(string(enc), "the API token") {
+				t.Errorf("the comment of nested is in plain text:\n%s", enc)
+			}
+
+			again, err := decryptSops(enc, ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again, got) {
+				t.Errorf("got\n%s\nwant\n%s", again, got)
+			}
+		})
+	}
+}
+
+func TestSopsComments(t *testing.T) {
+	id := identity(t)
+	plain := []byte("# head\nK: v # line\nnested:\n    # inner\n    on: true\nlist:\n    # first\n    - 1\n    - false\n# foot\n")
+
+	b, err := encryptSops(plain, nil, nil, []*age.X25519Recipient{id.Recipient()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"head", "line", "inner", "first", "foot"} {
+		if bytes.Contains(b, []byte(s)) {
+			t.Errorf("comment %q is in the encrypted file:\n%s", s, b)
+		}
+	}
+
+	got, err := decryptSops(b, []age.Identity{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("got\n%s\nwant\n%s", got, plain)
+	}
+
+	// comments are not part of the MAC
+	var doc yaml.Node
+	_ = yaml.Unmarshal(b, &doc)
+	doc.Content[0].HeadComment = "# changed"
+	changed, _ := marshal(&doc)
+	if _, err := decryptSops(changed, []age.Identity{id}); err != nil {
+		t.Errorf("a changed comment breaks the MAC: %v", err)
+	}
+}
+
+func TestSopsRecipients(t *testing.T) {
+	a, b := identity(t), identity(t)
+
+	enc, err := encryptSops([]byte("K: v\n"), nil, nil, []*age.X25519Recipient{a.Recipient()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decryptSops(enc, []age.Identity{b}); err == nil {
+		t.Fatalf("expected an error before b is added")
+	}
+
+	enc, err = encryptSops([]byte("K: v\n"), enc, []age.Identity{a}, []*age.X25519Recipient{a.Recipient(), b.Recipient()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f, _ := parseSops(enc); len(f.meta.Age) != 2 {
+		t.Errorf("got %d recipients, want 2", len(f.meta.Age))
+	}
+
+	if _, err := decryptSops(enc, []age.Identity{b}); err != nil {
+		t.Errorf("b can't decrypt once added: %v", err)
+	}
+}
+
+func TestAge(t *testing.T) {
+	id := identity(t)
+	plain := []byte("DB_PASSWORD=hunter2\n")
+
+	for _, armored := range []bool{true, false} {
+		b, err := encryptAge(plain, []*age.X25519Recipient{id.Recipient()}, armored)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if isArmored(b) != armored {
+			t.Errorf("armored %v, want %v", isArmored(b), armored)
+		}
+
+		got, err := decryptAge(b, []age.Identity{id})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, plain) {
+			t.Errorf("got %q, want %q", got, plain)
+		}
+
+		if _, err := decryptAge(b, []age.Identity{identity(t)}); err == nil || !strings.Contains(err.Error(), "recipient") {
+			t.Errorf("expected a no recipient error, got %v", err)
+		}
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	a, b := identity(t), identity(t)
+
+	dir := t.TempDir()
+	config := "creation_rules:\n" +
+		"  - path_regex: ^prod/\n" +
+		"    age: " + a.Recipient().String() + "\n" +
+		"  - age: " + a.Recipient().String() + "," + b.Recipient().String() + "\n"
+	if err := os.WriteFile(filepath.Join(dir, sopsConfigName), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "prod"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]int{"prod/secrets.enc.yaml": 1, "secrets.enc.yaml": 2} {
+		rs, err := Recipients(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rs) != want {
+			t.Errorf("%s: got %d recipients, want %d", name, len(rs), want)
+		}
+	}
+}
+
+func TestPlain(t *testing.T) {
+	for name, want := range map[string]string{
+		"secrets.enc.yaml": "secrets.yaml",
+		"secrets.enc.yml":  "secrets.yml",
+		".env.shared.age":  ".env.shared",
+		".env.local":       ".env.local",
+	} {
+		if got := Plain(name); got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+}
+
+func equal(a, b map[string]any) bool {
+	x, _ := yaml.Marshal(a)
+	y, _ := yaml.Marshal(b)
+	return bytes.Equal(x, y)
+}
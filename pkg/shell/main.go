@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"al.essio.dev/pkg/shellescape"
+	"github.com/abdheshnayak/inkube/flags"
 	"github.com/adrg/xdg"
 	"github.com/pkg/errors"
 )
@@ -92,6 +93,12 @@ func (d *Inkube) shellPath(envOpts EnvOptions) (path string, err error) {
 		}
 	}()
 
+	// The shell set in the inkube settings wins.
+	if flags.Shell != "" {
+		slog.Debug("using shell from inkube settings", "shell", flags.Shell)
+		return flags.Shell, nil
+	}
+
 	if !envOpts.Pure {
 		// First, check the SHELL environment variable.
 		path = os.Getenv("SHELL")
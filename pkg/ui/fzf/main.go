@@ -16,20 +16,27 @@ func WithPrompt(prompt string) Option {
 	return Option(mfzf.WithPrompt(fmt.Sprintf("%s %s ", prompt, text.Blue(":"))))
 }
 
-func FindOne[T any](items []T, itemFunc func(item T) string, options ...Option) (*T, error) {
-	f, err := mfzf.New(func() []mfzf.Option {
-		opts := make([]mfzf.Option, 0)
-		for _, o := range options {
-			opts = append(opts, mfzf.Option(o))
-		}
+func newFzf(options []Option, extra ...mfzf.Option) (*mfzf.FZF, error) {
+	opts := make([]mfzf.Option, 0, len(options)+len(extra)+1)
+	for _, o := range options {
+		opts = append(opts, mfzf.Option(o))
+	}
 
-		opts = append(opts, fzf.WithInputPlaceholder("search..."))
-		return opts
-	}()...)
+	opts = append(opts, extra...)
+	opts = append(opts, fzf.WithInputPlaceholder("search..."))
 
+	f, err := mfzf.New(opts...)
 	if err != nil {
 		return nil, fn.NewE(err, "failed to create fzf")
 	}
+	return f, nil
+}
+
+func FindOne[T any](items []T, itemFunc func(item T) string, options ...Option) (*T, error) {
+	f, err := newFzf(options)
+	if err != nil {
+		return nil, err
+	}
 
 	idxs, err := f.Find(items, func(i int) string {
 		return itemFunc(items[i])
@@ -43,3 +50,28 @@ func FindOne[T any](items []T, itemFunc func(item T) string, options ...Option)
 
 	return &items[selectedIndex], nil
 }
+
+// FindMany lets the user pick several items with tab, they are returned in
+// the order they were picked. Enter without picking any picks the item under
+// the cursor.
+func FindMany[T any](items []T, itemFunc func(item T) string, options ...Option) ([]T, error) {
+	f, err := newFzf(options, mfzf.WithNoLimit(true))
+	if err != nil {
+		return nil, err
+	}
+
+	idxs, _ := f.Find(items, func(i int) string {
+		return itemFunc(items[i])
+	})
+
+	if len(idxs) == 0 {
+		return nil, fn.Error("you have not selected any item")
+	}
+
+	selected := make([]T, 0, len(idxs))
+	for _, i := range idxs {
+		selected = append(selected, items[i])
+	}
+
+	return selected, nil
+}
@@ -24,6 +24,9 @@ type sclient struct {
 	verbose bool
 	quiet   bool
 
+	// filter is applied to every message, see SetFilter
+	filter func(string) string
+
 	started bool
 }
 
@@ -34,6 +37,7 @@ type Spinner interface {
 	Resume()
 	SetVerbose(verbose bool)
 	SetQuiet(quiet bool)
+	SetFilter(filter func(string) string)
 	IsRunning() bool
 }
 
@@ -80,6 +84,12 @@ func (s *sclient) SetQuiet(quiet bool) {
 	s.quiet = quiet
 }
 
+// SetFilter sets a function messages go through before they are shown, the
+// redactor of secrets.
+func (s *sclient) SetFilter(filter func(string) string) {
+	s.filter = filter
+}
+
 func (s *sclient) start() {
 	if s.quiet {
 		return
@@ -122,6 +132,10 @@ func (s *sclient) UpdateMessage(msg string) func() {
 		return s.stop
 	}
 
+	if s.filter != nil {
+		msg = s.filter(msg)
+	}
+
 	if !s.started {
 		s.started = true
 		s.start()